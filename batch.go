@@ -0,0 +1,165 @@
+package cube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchOptions tune SolveBatch. Zero values use the defaults.
+type BatchOptions struct {
+	// Workers is the number of cubes solved at once, each on its own
+	// goroutine. Defaults to 1.
+	Workers int
+	// Solve are the options used to solve each cube. Solving several
+	// cubes at once makes better use of the CPUs than splitting up the
+	// search for one cube, so Solve.Workers is best left at 1.
+	Solve SolveOptions
+	// OnResult, if set, is called with the result for each cube as soon
+	// as it's solved, which may not be in the order the cubes were given.
+	// Calls are never made concurrently.
+	OnResult func(BatchResult)
+}
+
+// BatchResult is the result of solving one cube of a batch.
+type BatchResult struct {
+	// Index is the position of the cube in the batch
+	Index    int
+	Solution Solution
+	Err      error
+}
+
+// SolveBatch solves every cube in cubes, spreading them across a pool of
+// worker goroutines which all share the same tables. The results are
+// returned in the same order as cubes. If ctx is done before every cube
+// is solved, the cubes that are left are given ctx's error.
+func SolveBatch(ctx context.Context, cubes []Cube, options BatchOptions) []BatchResult {
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	// build the tables up front rather than having every worker wait on
+	// the first one to get there. If the options are bad every cube gets
+	// the error when it's solved.
+	PrepareOptions(options.Solve)
+
+	results := make([]BatchResult, len(cubes))
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result := BatchResult{Index: i}
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+				} else {
+					result.Solution, result.Err = SolveWithOptions(ctx, cubes[i], options.Solve)
+				}
+				results[i] = result
+				if options.OnResult != nil {
+					mu.Lock()
+					options.OnResult(result)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range cubes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// BatchSummary describes the solutions found for a batch of cubes.
+type BatchSummary struct {
+	// Solved and Failed count the cubes which were and weren't solved
+	Solved int
+	Failed int
+	// Metric is the metric the lengths are counted in
+	Metric Metric
+	// Lengths maps each solution length to the number of solutions of
+	// that length
+	Lengths map[int]int
+	// MeanLength is the average solution length
+	MeanLength float64
+	// MeanDuration and MaxDuration are the average and longest time
+	// spent solving a cube
+	MeanDuration time.Duration
+	MaxDuration  time.Duration
+}
+
+// Summarize works out the length distribution and timings of a batch,
+// with the lengths counted in metric, usually the one it was solved in.
+func Summarize(results []BatchResult, metric Metric) BatchSummary {
+	summary := BatchSummary{Metric: metric, Lengths: make(map[int]int)}
+	totalLength := 0
+	var totalDuration time.Duration
+	for _, r := range results {
+		if r.Err != nil {
+			summary.Failed++
+			continue
+		}
+		summary.Solved++
+		length := r.Solution.Length(metric)
+		summary.Lengths[length]++
+		totalLength += length
+		totalDuration += r.Solution.Duration
+		if r.Solution.Duration > summary.MaxDuration {
+			summary.MaxDuration = r.Solution.Duration
+		}
+	}
+	if summary.Solved > 0 {
+		summary.MeanLength = float64(totalLength) / float64(summary.Solved)
+		summary.MeanDuration = totalDuration / time.Duration(summary.Solved)
+	}
+	return summary
+}
+
+// String returns the summary as a few lines of text, with one line for
+// each solution length.
+func (s BatchSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "solved %d of %d", s.Solved, s.Solved+s.Failed)
+	if s.Solved > 0 {
+		fmt.Fprintf(&b, ", mean %.2f%s, mean %s, max %s", s.MeanLength, s.Metric, s.MeanDuration, s.MaxDuration)
+	}
+	lengths := make([]int, 0, len(s.Lengths))
+	for length := range s.Lengths {
+		lengths = append(lengths, length)
+	}
+	sort.Ints(lengths)
+	for _, length := range lengths {
+		fmt.Fprintf(&b, "\n%3d%s: %d", length, s.Metric, s.Lengths[length])
+	}
+	return b.String()
+}
+
+// MarshalJSON encodes the summary with durations in milliseconds, like
+// Solution.
+func (s BatchSummary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Solved         int         `json:"solved"`
+		Failed         int         `json:"failed"`
+		Metric         string      `json:"metric"`
+		Lengths        map[int]int `json:"lengths"`
+		MeanLength     float64     `json:"meanLength"`
+		MeanDurationMS float64     `json:"meanDurationMs"`
+		MaxDurationMS  float64     `json:"maxDurationMs"`
+	}{
+		Solved:         s.Solved,
+		Failed:         s.Failed,
+		Metric:         s.Metric.String(),
+		Lengths:        s.Lengths,
+		MeanLength:     s.MeanLength,
+		MeanDurationMS: float64(s.MeanDuration) / float64(time.Millisecond),
+		MaxDurationMS:  float64(s.MaxDuration) / float64(time.Millisecond),
+	})
+}
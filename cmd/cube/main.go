@@ -0,0 +1,412 @@
+// Command cube solves Rubik's Cubes from the command line.
+//
+// Each argument is a cube to solve, either a scramble such as "R U R' U'"
+// or a 54 character facelet string (see cube.FromFacelets), which is
+// guessed from its letters unless -facelets or -scramble says. With -to,
+// cubes are solved to another cube instead, such as a pattern, and with
+// -step only one step of CFOP is solved, such as the cross. With no
+// arguments, cubes are read from stdin one per line. Lines may also be
+// JSON objects with a "scramble" or "facelets" field, so JSONL files can
+// be piped straight in. Solutions are printed one per line in the same
+// order.
+//
+// With -batch, every cube is read first and then they're solved several
+// at a time, one per worker, with each line showing the solution's
+// length and how long it took. A summary of the solution lengths is
+// printed to stderr at the end.
+//
+//	cube [flags] [cube ...]
+package main
+
+import (
+	"bufio"
+	"context"
+	"cube"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	format   = flag.String("format", "plain", "output `format`, plain or json")
+	metrics  = flag.String("metrics", "", "comma separated `metrics` to print after each plain solution: htm, qtm, stm (default the -metric with -batch)")
+	metric   = flag.String("metric", "htm", "`metric` to keep solutions short in, htm, qtm or stm, which writes solutions with slice turns")
+	timeout  = flag.Duration("time", 0, "time limit per cube, after which the best solution so far is printed (0 for none)")
+	length   = flag.Int("length", 0, "stop searching once a solution of this many moves or less is found (default 21 htm, 27 qtm, 20 stm)")
+	optimal  = flag.Bool("optimal", false, "find optimal solutions, which is only quick for cubes up to around 14 moves from solved, so set -time too")
+	workers  = flag.Int("workers", runtime.NumCPU(), "goroutines to search each cube with, or with -batch the number of cubes to solve at once")
+	batch    = flag.Bool("batch", false, "solve every cube at once across the workers and print a summary")
+	faces    = flag.String("faces", "", "only turn these `faces`, e.g. RUF, rather than all six")
+	step     = flag.String("step", "", "only solve one `step` of CFOP, as short as possible: cross, fl, fr, br or bl for the cross and that pair, or oll once F2L is solved")
+	to       = flag.String("to", "", "solve each cube to this `cube`, a scramble or facelets, rather than to solved")
+	facelets = flag.Bool("facelets", false, "read every cube as a facelet string rather than guessing")
+	scramble = flag.Bool("scramble", false, "read every cube as a scramble rather than guessing")
+	tables   = flag.String("tables", "", "`dir` to load and save tables in (default is the user cache dir)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: cube [flags] [cube ...]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *format != "plain" && *format != "json" {
+		fail(fmt.Errorf("unknown format %q", *format))
+	}
+	if *facelets && *scramble {
+		fail(errors.New("only one of -facelets and -scramble can be given"))
+	}
+	for _, m := range splitMetrics(*metrics) {
+		if _, ok := metricFuncs[m]; !ok {
+			fail(fmt.Errorf("unknown metric %q", m))
+		}
+	}
+	searchMetric, err := parseMetric(*metric)
+	if err != nil {
+		fail(err)
+	}
+	var allowed []cube.Move
+	if *faces != "" {
+		if allowed, err = cube.FaceTurns(*faces); err != nil {
+			fail(err)
+		}
+	}
+	options := solveOptions(searchMetric, allowed)
+	if *step != "" {
+		mask, err := cube.StepMask(*step)
+		if err != nil {
+			fail(err)
+		}
+		options.Mask = &mask
+	}
+	if *to != "" {
+		c, err := parse(*to)
+		if err == nil {
+			err = c.Validate()
+		}
+		if err != nil {
+			fail(fmt.Errorf("-to: %w", err))
+		}
+		target = &c
+	}
+	if *tables != "" {
+		cube.SetTableDir(*tables)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	failed := false
+	each := func(input string) {
+		solution, err := solve(input, options)
+		if err != nil {
+			failed = true
+		}
+		if err := write(out, input, solution, err); err != nil {
+			fail(err)
+		}
+		// flush as we go so scripts reading a pipe see each solution
+		// as soon as it's ready
+		if err := out.Flush(); err != nil {
+			fail(err)
+		}
+	}
+	switch {
+	case *batch:
+		inputs := flag.Args()
+		if len(inputs) == 0 {
+			var err error
+			if inputs, err = readLines(os.Stdin); err != nil {
+				fail(err)
+			}
+		}
+		failed = solveBatch(out, inputs, options)
+	case flag.NArg() > 0:
+		for _, arg := range flag.Args() {
+			each(arg)
+		}
+	default:
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				each(line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			fail(err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// readLines reads every non blank line of r.
+func readLines(r io.Reader) ([]string, error) {
+	var result []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result, scanner.Err()
+}
+
+func solveOptions(metric cube.Metric, allowed []cube.Move) cube.SolveOptions {
+	return cube.SolveOptions{
+		Metric:    metric,
+		Moves:     allowed,
+		MaxLength: *length,
+		Timeout:   *timeout,
+		Optimal:   *optimal,
+		Workers:   *workers,
+	}
+}
+
+// parseMetric reads the -metric flag.
+func parseMetric(s string) (cube.Metric, error) {
+	switch strings.ToLower(s) {
+	case "htm":
+		return cube.HTM, nil
+	case "qtm":
+		return cube.QTM, nil
+	case "stm":
+		return cube.STM, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", s)
+}
+
+// target is the -to cube, or nil to solve cubes to solved
+var target *cube.Cube
+
+func solve(input string, options cube.SolveOptions) (cube.Solution, error) {
+	c, err := parse(input)
+	if err != nil {
+		return cube.Solution{}, err
+	}
+	if err := checkStep(c); err != nil {
+		return cube.Solution{}, err
+	}
+	if target != nil {
+		return cube.SolveToWithOptions(context.Background(), c, *target, options)
+	}
+	return cube.SolveWithOptions(context.Background(), c, options)
+}
+
+// checkStep returns an error if c isn't ready for the -step, see
+// cube.StepReady. A cube that isn't valid is left for the solve to
+// report.
+func checkStep(c cube.Cube) error {
+	if *step == "" || c.Validate() != nil {
+		return nil
+	}
+	if target != nil {
+		c = target.Inverse().Compose(c)
+	}
+	if !cube.StepReady(*step, c) {
+		return fmt.Errorf("the steps before %s aren't solved", *step)
+	}
+	return nil
+}
+
+// solveBatch solves every input across the workers, writing out each
+// result in order as soon as it and every result before it are ready.
+// It reports whether any cube couldn't be solved.
+func solveBatch(out *bufio.Writer, inputs []string, options cube.SolveOptions) bool {
+	results := make([]cube.BatchResult, len(inputs))
+	done := make([]bool, len(inputs))
+	next := 0
+	flush := func() {
+		for next < len(inputs) && done[next] {
+			r := results[next]
+			if err := write(out, inputs[next], r.Solution, r.Err); err != nil {
+				fail(err)
+			}
+			next++
+		}
+		if err := out.Flush(); err != nil {
+			fail(err)
+		}
+	}
+
+	// inputs which don't parse never make it to the batch, so keep
+	// track of where each cube in the batch came from
+	var cubes []cube.Cube
+	var positions []int
+	for i, input := range inputs {
+		c, err := parse(input)
+		if err == nil {
+			err = checkStep(c)
+		}
+		if err != nil {
+			results[i] = cube.BatchResult{Index: i, Err: err}
+			done[i] = true
+			continue
+		}
+		if target != nil && c.Validate() == nil {
+			// SolveBatch solves to solved, so give it the cube SolveTo
+			// would solve
+			c = target.Inverse().Compose(c)
+		}
+		cubes = append(cubes, c)
+		positions = append(positions, i)
+	}
+	flush()
+
+	options.Workers = 1
+	cube.SolveBatch(context.Background(), cubes, cube.BatchOptions{
+		Workers: *workers,
+		Solve:   options,
+		OnResult: func(r cube.BatchResult) {
+			r.Index = positions[r.Index]
+			results[r.Index] = r
+			done[r.Index] = true
+			flush()
+		},
+	})
+
+	summary := cube.Summarize(results, options.Metric)
+	if *format == "json" {
+		b, err := json.Marshal(summary)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", b)
+	} else {
+		fmt.Fprintln(os.Stderr, summary)
+	}
+	return summary.Failed > 0
+}
+
+// parse reads a cube from a line of input, which is either a scramble,
+// a facelet string or a JSON object holding one of them.
+func parse(input string) (cube.Cube, error) {
+	if strings.HasPrefix(input, "{") {
+		var line struct {
+			Scramble *string `json:"scramble"`
+			Facelets *string `json:"facelets"`
+		}
+		if err := json.Unmarshal([]byte(input), &line); err != nil {
+			return cube.Cube{}, err
+		}
+		switch {
+		case line.Facelets != nil:
+			return cube.FromFacelets(*line.Facelets)
+		case line.Scramble != nil:
+			return cube.FromScramble(*line.Scramble)
+		default:
+			return cube.Cube{}, errors.New(`expected a "scramble" or "facelets" field`)
+		}
+	}
+	if *facelets || !*scramble && isFacelets(input) {
+		return cube.FromFacelets(input)
+	}
+	return cube.FromScramble(input)
+}
+
+// isFacelets guesses whether input is a facelet string rather than a
+// scramble: 54 characters, ignoring whitespace, made up of 6 colors with
+// 9 of each and a different color on each center. A scramble would
+// almost never be made of exactly that, and -facelets and -scramble
+// settle it either way.
+func isFacelets(input string) bool {
+	var stickers []rune
+	counts := make(map[rune]int)
+	for _, r := range input {
+		if !unicode.IsSpace(r) {
+			stickers = append(stickers, r)
+			counts[r]++
+		}
+	}
+	if len(stickers) != 54 || len(counts) != 6 {
+		return false
+	}
+	centers := make(map[rune]bool)
+	for face := 0; face < 6; face++ {
+		centers[stickers[face*9+4]] = true
+	}
+	for _, n := range counts {
+		if n != 9 {
+			return false
+		}
+	}
+	return len(centers) == 6
+}
+
+var metricFuncs = map[string]func(cube.Solution) int{
+	"htm": cube.Solution.HTM,
+	"qtm": cube.Solution.QTM,
+	"stm": cube.Solution.STM,
+}
+
+func splitMetrics(s string) []string {
+	var result []string
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.ToLower(strings.TrimSpace(m)); m != "" {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func write(w io.Writer, input string, solution cube.Solution, err error) error {
+	if *format == "json" {
+		result := struct {
+			Input    string         `json:"input"`
+			Solution *cube.Solution `json:"result,omitempty"`
+			Error    string         `json:"error,omitempty"`
+			Nodes    int64          `json:"nodes,omitempty"`
+		}{Input: input}
+		if err != nil {
+			result.Error = err.Error()
+			result.Nodes = solution.Nodes
+		} else {
+			result.Solution = &solution
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	if err != nil {
+		if solution.Nodes > 0 {
+			// the search ran out of time, so say how far it got
+			err = fmt.Errorf("%w (%d nodes searched)", err, solution.Nodes)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", input, err)
+		// keep one line per cube so the output lines up with the input
+		_, err = fmt.Fprintln(w)
+		return err
+	}
+	line := solution.String()
+	ms := splitMetrics(*metrics)
+	if *batch && len(ms) == 0 {
+		ms = []string{strings.ToLower(*metric)}
+	}
+	if len(ms) > 0 {
+		lengths := make([]string, len(ms))
+		for i, m := range ms {
+			lengths[i] = fmt.Sprintf("%d%s", metricFuncs[m](solution), m)
+		}
+		line += " (" + strings.Join(lengths, " ") + ")"
+	}
+	if *batch {
+		line += fmt.Sprintf(" %.3fms", float64(solution.Duration)/float64(time.Millisecond))
+	}
+	_, err = fmt.Fprintln(w, line)
+	return err
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "cube:", err)
+	os.Exit(1)
+}
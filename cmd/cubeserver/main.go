@@ -0,0 +1,363 @@
+// Command cubeserver serves the solver over HTTP.
+//
+//	POST /solve     {"scramble": "R U R' U'"} or {"facelets": "UUU..."}
+//	                solves the cube. "timeLimitMs", "maxLength",
+//	                "metric" ("htm", "qtm" or "stm"), "faces" (e.g.
+//	                "RUF", to only turn those faces, if -faces allows
+//	                it) and "step" ("cross", "fl", "fr", "br", "bl"
+//	                or "oll", to only solve that step, see
+//	                cube.StepMask) tune the search, see
+//	                cube.SolveOptions.
+//	GET  /scramble  returns a random state scramble, ?seed=n to get the
+//	                same scramble every time
+//	POST /validate  checks whether a cube can be solved
+//
+// Every response is JSON, and errors are {"error": "..."}. Each solve
+// gets a time limit, which requests can lower but not raise, and only a
+// limited number of cubes are solved or scrambled at once. Requests wait
+// for a free slot within their time limit, and the search stops when it
+// runs out, so a burst of slow requests can't hold up everyone else for
+// longer than that.
+//
+// Every table a request can need is built or loaded at startup, so no
+// request has to wait while one is built. That includes the tables for
+// each step, and for each set of faces given with -faces, which are the
+// only sets requests can restrict the search to. A set which the two
+// phase search can't use, such as RU, needs the optimal search's 125MB
+// of tables.
+package main
+
+import (
+	"context"
+	"cube"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	addr        = flag.String("addr", ":8080", "address to listen on")
+	maxTime     = flag.Duration("max-time", 10*time.Second, "longest time limit a request can have")
+	defaultTime = flag.Duration("time", 2*time.Second, "time limit for requests which don't give one")
+	concurrency = flag.Int("concurrency", runtime.NumCPU(), "number of cubes to solve at once")
+	tables      = flag.String("tables", "", "`dir` to load and save tables in (default is the user cache dir)")
+	faceSets    = flag.String("faces", "", "comma separated `sets` of faces, e.g. ULFRD,RU, that requests can restrict the search to")
+)
+
+// maxBodySize limits request bodies, which only ever hold one cube
+const maxBodySize = 1 << 12
+
+// slots holds a token for every solve or scramble in progress
+var slots chan struct{}
+
+// allowedFaces are the moves of each set of faces in -faces, by
+// normalizeFaces
+var allowedFaces = make(map[string][]cube.Move)
+
+var allMetrics = []cube.Metric{cube.HTM, cube.QTM, cube.STM}
+
+// steps are the steps requests can solve, see cube.StepMask
+var steps = []string{"cross", "fl", "fr", "br", "bl", "oll"}
+
+func main() {
+	flag.Parse()
+	if *concurrency <= 0 {
+		log.Fatal("concurrency must be at least 1")
+	}
+	slots = make(chan struct{}, *concurrency)
+	if *tables != "" {
+		cube.SetTableDir(*tables)
+	}
+
+	moveSets := [][]cube.Move{nil}
+	if *faceSets != "" {
+		for _, faces := range strings.Split(*faceSets, ",") {
+			moves, err := cube.FaceTurns(faces)
+			if err != nil {
+				log.Fatalf("-faces: %v", err)
+			}
+			allowedFaces[normalizeFaces(faces)] = moves
+			moveSets = append(moveSets, moves)
+		}
+	}
+	log.Print("preparing tables")
+	for _, moves := range moveSets {
+		for _, metric := range allMetrics {
+			prepare(cube.SolveOptions{Metric: metric, Moves: moves})
+			for _, step := range steps {
+				mask, err := cube.StepMask(step)
+				if err != nil {
+					log.Fatal(err)
+				}
+				prepare(cube.SolveOptions{Metric: metric, Moves: moves, Mask: &mask})
+			}
+		}
+	}
+
+	http.HandleFunc("/solve", handleSolve)
+	http.HandleFunc("/scramble", handleScramble)
+	http.HandleFunc("/validate", handleValidate)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// cubeRequest is the body of /solve and /validate requests. Exactly one
+// of Scramble and Facelets should be set.
+type cubeRequest struct {
+	Scramble    *string `json:"scramble"`
+	Facelets    *string `json:"facelets"`
+	TimeLimitMS int     `json:"timeLimitMs"`
+	MaxLength   int     `json:"maxLength"`
+	Metric      string  `json:"metric"`
+	Faces       string  `json:"faces"`
+	Step        string  `json:"step"`
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request) (cubeRequest, error) {
+	var req cubeRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return req, err
+	}
+	switch {
+	case req.Scramble != nil && req.Facelets != nil:
+		return req, errors.New(`only one of "scramble" and "facelets" can be given`)
+	case req.Scramble == nil && req.Facelets == nil:
+		return req, errors.New(`expected a "scramble" or "facelets" field`)
+	}
+	return req, nil
+}
+
+// parseCube reads the cube out of a request.
+func (req cubeRequest) parseCube() (cube.Cube, error) {
+	if req.Facelets != nil {
+		return cube.FromFacelets(*req.Facelets)
+	}
+	return cube.FromScramble(*req.Scramble)
+}
+
+// parseMetric reads the metric out of a request, which defaults to HTM.
+func (req cubeRequest) parseMetric() (cube.Metric, error) {
+	switch req.Metric {
+	case "", "htm":
+		return cube.HTM, nil
+	case "qtm":
+		return cube.QTM, nil
+	case "stm":
+		return cube.STM, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", req.Metric)
+}
+
+// prepare builds the tables for a set of options a request can ask for.
+func prepare(options cube.SolveOptions) {
+	if err := cube.PrepareOptions(options); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// normalizeFaces writes a set of faces in a fixed order, so the same set
+// is always found in allowedFaces however it's written.
+func normalizeFaces(faces string) string {
+	var b strings.Builder
+	for _, face := range "ULFRBD" {
+		if strings.ContainsRune(strings.ToUpper(faces), face) {
+			b.WriteRune(face)
+		}
+	}
+	return b.String()
+}
+
+func handleSolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	req, err := decodeRequest(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	c, err := req.parseCube()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	metric, err := req.parseMetric()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var allowed []cube.Move
+	if req.Faces != "" {
+		if _, err := cube.FaceTurns(req.Faces); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var ok bool
+		if allowed, ok = allowedFaces[normalizeFaces(req.Faces)]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("faces %q aren't allowed on this server", req.Faces))
+			return
+		}
+	}
+
+	limit := *defaultTime
+	if req.TimeLimitMS > 0 {
+		limit = time.Duration(req.TimeLimitMS) * time.Millisecond
+	}
+	limit = min(limit, *maxTime)
+	options := cube.SolveOptions{
+		Metric:    metric,
+		Moves:     allowed,
+		MaxLength: req.MaxLength,
+	}
+	if req.Step != "" {
+		mask, err := cube.StepMask(req.Step)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !cube.StepReady(req.Step, c) {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("the steps before %s aren't solved", req.Step))
+			return
+		}
+		options.Mask = &mask
+	}
+
+	// the time spent waiting for a slot comes out of the request's time
+	// limit, and whatever is left is the search's budget
+	ctx, cancel := context.WithTimeout(r.Context(), limit)
+	defer cancel()
+	if !acquireSlot(ctx) {
+		writeError(w, http.StatusServiceUnavailable, errServerBusy)
+		return
+	}
+	defer releaseSlot()
+	solution, err := cube.SolveWithOptions(ctx, c, options)
+	switch {
+	case errors.Is(err, cube.ErrBudgetExceeded), errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, cube.ErrNotReachable):
+		writeError(w, http.StatusUnprocessableEntity, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, solution)
+	}
+}
+
+var errServerBusy = errors.New("server busy, try again later")
+
+// acquireSlot waits for a free slot until ctx is done, and reports
+// whether it got one. A slot that frees up just as ctx runs out isn't
+// taken, since there'd be no time left to use it.
+func acquireSlot(ctx context.Context) bool {
+	select {
+	case slots <- struct{}{}:
+		if ctx.Err() != nil {
+			releaseSlot()
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func releaseSlot() {
+	<-slots
+}
+
+func handleScramble(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	var rng *rand.Rand
+	if q := r.URL.Query().Get("seed"); q != "" {
+		seed, err := strconv.ParseInt(q, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("seed must be a whole number"))
+			return
+		}
+		rng = rand.New(rand.NewSource(seed))
+	}
+	// a scramble is a solve too, so it waits for a slot like one
+	ctx, cancel := context.WithTimeout(r.Context(), *defaultTime)
+	defer cancel()
+	if !acquireSlot(ctx) {
+		writeError(w, http.StatusServiceUnavailable, errServerBusy)
+		return
+	}
+	defer releaseSlot()
+	scramble, err := cube.RandomScramble(rng)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"scramble": cube.FormatMoves(scramble)})
+}
+
+func handleValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	req, err := decodeRequest(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result := struct {
+		Valid bool   `json:"valid"`
+		Error string `json:"error,omitempty"`
+		Piece string `json:"piece,omitempty"`
+	}{Valid: true}
+	c, err := req.parseCube()
+	if err == nil {
+		err = c.Validate()
+	}
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		var invalid *cube.InvalidCubeError
+		var badFacelets *cube.FaceletError
+		switch {
+		case errors.As(err, &invalid):
+			result.Piece = invalid.Piece
+		case errors.As(err, &badFacelets):
+			result.Piece = badFacelets.Piece
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
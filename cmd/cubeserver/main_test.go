@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cube"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	slots = make(chan struct{}, 1)
+	moves, err := cube.FaceTurns("ULFRD")
+	if err != nil {
+		panic(err)
+	}
+	allowedFaces[normalizeFaces("ULFRD")] = moves
+	// build the tables up front, as main does, so no request runs out
+	// of time building them
+	for _, moves := range [][]cube.Move{nil, moves} {
+		prepare(cube.SolveOptions{Moves: moves})
+	}
+	for _, step := range []string{"cross", "oll"} {
+		mask, err := cube.StepMask(step)
+		if err != nil {
+			panic(err)
+		}
+		prepare(cube.SolveOptions{Mask: &mask})
+	}
+	os.Exit(m.Run())
+}
+
+// serve sends a request to handler and decodes the JSON response into v,
+// returning the status code.
+func serve(t *testing.T, handler http.HandlerFunc, method, target, body string, v any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type is %q", got)
+	}
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	return w.Code
+}
+
+// twistedFacelets is a solved cube with one corner twisted
+func twistedFacelets() string {
+	c := cube.Solved()
+	c.Corners[0].Orientation = 1
+	return c.Facelets()
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		// cube is the scramble the solution has to solve, when it's 200
+		cube string
+		// faces are the only faces the solution may turn
+		faces string
+	}{
+		{"scramble", `{"scramble": "R U R' U'"}`, http.StatusOK, "R U R' U'", ""},
+		{"facelets", `{"facelets": "UUUUUUUUUBBBRRRRRRRRRFFFFFFDDDDDDDDDFFFLLLLLLLLLBBBBBB"}`, http.StatusOK, "U", ""},
+		{"options", `{"scramble": "R U F2 L' B D", "metric": "stm", "maxLength": 30, "timeLimitMs": 500}`, http.StatusOK, "R U F2 L' B D", ""},
+		{"faces", `{"scramble": "B R2 U", "faces": "dulfr"}`, http.StatusOK, "B R2 U", "ULFRD"},
+		{"cross", `{"scramble": "R U F", "step": "cross"}`, http.StatusOK, "", ""},
+		{"oll after F2L", `{"scramble": "R U R' U R U2 R'", "step": "oll"}`, http.StatusOK, "", ""},
+
+		{"bad json", `{"scramble": `, http.StatusBadRequest, "", ""},
+		{"unknown field", `{"scramble": "R", "colour": "red"}`, http.StatusBadRequest, "", ""},
+		{"no cube", `{"metric": "htm"}`, http.StatusBadRequest, "", ""},
+		{"two cubes", `{"scramble": "R", "facelets": "UUU"}`, http.StatusBadRequest, "", ""},
+		{"bad scramble", `{"scramble": "R X"}`, http.StatusBadRequest, "", ""},
+		{"bad facelets", `{"facelets": "UUU"}`, http.StatusBadRequest, "", ""},
+		{"bad metric", `{"scramble": "R", "metric": "etm"}`, http.StatusBadRequest, "", ""},
+		{"bad faces", `{"scramble": "R", "faces": "RX"}`, http.StatusBadRequest, "", ""},
+		{"faces not allowed", `{"scramble": "R", "faces": "RU"}`, http.StatusBadRequest, "", ""},
+		{"bad step", `{"scramble": "R", "step": "f2l"}`, http.StatusBadRequest, "", ""},
+		{"invalid cube", `{"facelets": "` + twistedFacelets() + `"}`, http.StatusUnprocessableEntity, "", ""},
+		{"oll too early", `{"scramble": "R U F", "step": "oll"}`, http.StatusUnprocessableEntity, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result struct {
+				Solution string `json:"solution"`
+				Error    string `json:"error"`
+			}
+			status := serve(t, handleSolve, http.MethodPost, "/solve", tt.body, &result)
+			if status != tt.status {
+				t.Fatalf("got %d %+v, want %d", status, result, tt.status)
+			}
+			if status != http.StatusOK {
+				if result.Error == "" {
+					t.Error("no error in the response")
+				}
+				return
+			}
+			solution, err := cube.ParseMoves(result.Solution)
+			if err != nil {
+				t.Fatalf("%q: %v", result.Solution, err)
+			}
+			if tt.cube != "" {
+				c, err := cube.FromScramble(tt.cube)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !c.ApplySequence(solution).IsSolved() {
+					t.Errorf("%q doesn't solve %q", result.Solution, tt.cube)
+				}
+			}
+			for _, token := range strings.Fields(result.Solution) {
+				if tt.faces != "" && !strings.ContainsRune(tt.faces, rune(token[0])) {
+					t.Errorf("%q turns %s", result.Solution, token)
+				}
+			}
+		})
+	}
+}
+
+func TestSolveMethod(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleSolve(w, httptest.NewRequest(http.MethodGet, "/solve", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if got := w.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow is %q, want %q", got, http.MethodPost)
+	}
+}
+
+func TestSolveBusy(t *testing.T) {
+	// take every slot, so the request runs out of time waiting for one
+	for i := 0; i < cap(slots); i++ {
+		slots <- struct{}{}
+	}
+	defer func() {
+		for i := 0; i < cap(slots); i++ {
+			releaseSlot()
+		}
+	}()
+	var result struct {
+		Error string `json:"error"`
+	}
+	status := serve(t, handleSolve, http.MethodPost, "/solve", `{"scramble": "R", "timeLimitMs": 10}`, &result)
+	if status != http.StatusServiceUnavailable || result.Error != errServerBusy.Error() {
+		t.Errorf("got %d %q, want %d %q", status, result.Error, http.StatusServiceUnavailable, errServerBusy)
+	}
+}
+
+func TestScramble(t *testing.T) {
+	var a, b struct {
+		Scramble string `json:"scramble"`
+	}
+	if status := serve(t, handleScramble, http.MethodGet, "/scramble?seed=1", "", &a); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if status := serve(t, handleScramble, http.MethodGet, "/scramble?seed=1", "", &b); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if a.Scramble != b.Scramble {
+		t.Errorf("seed 1 gave %q and %q", a.Scramble, b.Scramble)
+	}
+	if _, err := cube.FromScramble(a.Scramble); err != nil {
+		t.Errorf("%q: %v", a.Scramble, err)
+	}
+
+	var result struct {
+		Error string `json:"error"`
+	}
+	if status := serve(t, handleScramble, http.MethodGet, "/scramble?seed=x", "", &result); status != http.StatusBadRequest {
+		t.Errorf("bad seed: got %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := serve(t, handleScramble, http.MethodPost, "/scramble", "", &result); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+		piece string
+	}{
+		{"scramble", `{"scramble": "R U"}`, true, ""},
+		{"facelets", `{"facelets": "UUUUUUUUURRRRRRRRRFFFFFFFFFDDDDDDDDDLLLLLLLLLBBBBBBBBB"}`, true, ""},
+		{"twisted corner", `{"facelets": "` + twistedFacelets() + `"}`, false, ""},
+		{"impossible edge", `{"facelets": "UUUUUUUFURRRRRRRRRFFFFFFFFFDDDDDDDDDLLLLLLLLLBBBBBBBBB"}`, false, "UF"},
+		{"bad scramble", `{"scramble": "R X"}`, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result struct {
+				Valid bool   `json:"valid"`
+				Error string `json:"error"`
+				Piece string `json:"piece"`
+			}
+			status := serve(t, handleValidate, http.MethodPost, "/validate", tt.body, &result)
+			if status != http.StatusOK {
+				t.Fatalf("got %d, want %d", status, http.StatusOK)
+			}
+			if result.Valid != tt.valid || result.Piece != tt.piece {
+				t.Errorf("got %+v, want valid %t and piece %q", result, tt.valid, tt.piece)
+			}
+			if !result.Valid && result.Error == "" {
+				t.Error("no error for an invalid cube")
+			}
+		})
+	}
+
+	var result struct {
+		Error string `json:"error"`
+	}
+	if status := serve(t, handleValidate, http.MethodPost, "/validate", `{}`, &result); status != http.StatusBadRequest {
+		t.Errorf("no cube: got %d, want %d", status, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,25 @@
+// Command gentables writes the two phase search's tables into a
+// directory, ready to be embedded with the embedtables build tag.
+//
+//	go run ./cmd/gentables tables
+package main
+
+import (
+	"cube"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: gentables dir")
+		os.Exit(2)
+	}
+	// build every table from scratch rather than copying whatever is
+	// in the cache
+	cube.SetTableDir("")
+	if err := cube.WriteTables(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
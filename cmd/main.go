@@ -1,26 +1,62 @@
+//go:build js && wasm
+
+// Command main is the solver for the web page in web, built as
+// web/main.wasm. The binary is checked in so the page can be served as
+// it is, so rebuild it with go generate cmd/main.go whenever this file
+// or the solver changes. It's built without VCS stamps, so the same
+// source always gives the same binary.
 package main
 
+//go:generate env GOOS=js GOARCH=wasm go build -buildvcs=false -o ../web/main.wasm .
+//go:generate cp $GOROOT/lib/wasm/wasm_exec.js ../web/
+
 import (
+	"context"
 	"cube"
 	"fmt"
 	"syscall/js"
 	"time"
 )
 
+// solve takes a scramble and optionally a time limit in milliseconds,
+// after which the best solution found so far is returned, and a callback
+// which is called with each shorter solution as the search finds it.
 func solve(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return "Error: No argument provided"
 	}
 
 	message := args[0].String()
+	ctx := context.Background()
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(args[1].Int())*time.Millisecond)
+		defer cancel()
+	}
+
+	var options cube.SolveOptions
+	if len(args) > 2 && args[2].Type() == js.TypeFunction {
+		onSolution := args[2]
+		options.OnSolution = func(s cube.Solution) {
+			onSolution.Invoke(js.ValueOf(map[string]interface{}{
+				"solution": s.String(),
+				"duration": fmt.Sprintf("Found in %s, still searching...", s.Duration),
+			}))
+		}
+	}
+
 	start := time.Now()
-	solution, err := cube.Solve(message)
+	c, err := cube.FromScramble(message)
+	if err != nil {
+		return err.Error()
+	}
+	solution, err := cube.SolveWithOptions(ctx, c, options)
 	duration := time.Since(start)
 	if err != nil {
 		return err.Error()
 	}
 	return js.ValueOf(map[string]interface{}{
-		"solution": solution,
+		"solution": solution.String(),
 		"duration": fmt.Sprintf("Solved in %s!", duration),
 	})
 }
@@ -6,7 +6,7 @@ package cube
 // from 0-2047. There are 2^11 possible edge orientations.
 // the orientation of the last edge is determined by the
 // rest
-func toEOCoordinate(c cube) int {
+func toEOCoordinate(c Cube) int {
 	result := 0
 	for _, e := range c.Edges[0 : edgeCount-1] {
 		result = (result << 1) | (e.Orientation & 1)
@@ -18,8 +18,8 @@ func toEOCoordinate(c cube) int {
 // it does not produce an actually valid Cube - just valid from
 // the perspective of EO. This means that this Cube is not
 // suitable for anything other than generating pruning tables.
-func fromEOCoordinate(c int) cube {
-	var result cube
+func fromEOCoordinate(c int) Cube {
+	var result Cube
 	totalOrientation := 0
 	for i := edgeCount - 2; i >= 0; i-- {
 		result.Edges[i].Orientation = c & 1
@@ -36,7 +36,7 @@ func fromEOCoordinate(c int) cube {
 // coordinate space. There are 3^7 possible corner orientations.
 // The orientation of the last corner is determined by the rest.
 // The total of all corner orientations must be divisible by 3.
-func toCOCoordinate(c cube) int {
+func toCOCoordinate(c Cube) int {
 	result := 0
 	for _, c := range c.Corners[0 : cornerCount-1] {
 		result = result*3 + c.Orientation
@@ -47,8 +47,8 @@ func toCOCoordinate(c cube) int {
 // fromCOCoordinate converts a coordinate in the CO space into
 // a Cube. This Cube is only valid from the perspective of CO and
 // is not suitable for anything other than generating pruning tables.
-func fromCOCoordinate(c int) cube {
-	var result cube
+func fromCOCoordinate(c int) Cube {
+	var result Cube
 	totalOrientation := 0
 	for i := cornerCount - 2; i >= 0; i-- {
 		result.Corners[i].Orientation = c % 3
@@ -66,7 +66,7 @@ func fromCOCoordinate(c int) cube {
 // configurations of the 4 edge slice edges, order independant. The solved
 // state of this problem space is when all 4 edges of the E slice are
 // in the E slice, but not necessarily solved yet.
-func toESliceP1Coordinate(c cube) int {
+func toESliceP1Coordinate(c Cube) int {
 	result := 0
 
 	k := 1
@@ -84,8 +84,8 @@ func toESliceP1Coordinate(c cube) int {
 // this cube will have the 4 E slice edges taking up the 4 specific
 // in any order. When c = 0, the will generate a cube with all 4 E slice
 // edges in the E slice, but potentially out of order.
-func fromESliceP1Coordinate(c int) cube {
-	var result cube
+func fromESliceP1Coordinate(c int) Cube {
+	var result Cube
 
 	// i := 0
 
@@ -103,6 +103,26 @@ func fromESliceP1Coordinate(c int) cube {
 	return result
 }
 
+// fromFlipSliceCoordinate creates a cube with the given EO and E slice
+// coordinates. Unlike the other coordinate cubes this one has every edge
+// in it, the UD edges filling in the positions outside the slice in
+// order, so that it can be looked at through a symmetry and converted
+// back into coordinates.
+func fromFlipSliceCoordinate(eo, eSlice int) Cube {
+	result := fromESliceP1Coordinate(eSlice)
+	orientations := fromEOCoordinate(eo)
+	next := 0
+	for i := range result.Edges {
+		if result.Edges[i].Index < edgeFL {
+			result.Edges[i].Index = next
+			next++
+		}
+		result.Edges[i].Orientation = orientations.Edges[i].Orientation
+	}
+	result.Corners = cubeSolved.Corners
+	return result
+}
+
 // cNK computes binomial coefficent N choose K
 func cNK(n, k int) int {
 	if k > n {
@@ -121,7 +141,7 @@ func cNK(n, k int) int {
 // toCPCoordinate converts a Cube into the corner permutatation coordinate
 // space. There are 8! possible corner permutations, where 0 is the solved
 // state of all corner permutations
-func toCPCoordinate(c cube) int {
+func toCPCoordinate(c Cube) int {
 	result := 0
 	for i := 0; i < cornerCount; i++ {
 		count := 0
@@ -137,8 +157,8 @@ func toCPCoordinate(c cube) int {
 
 // fromCPCoordinate converts a coordinate in the CP space back into a Cube.
 // This cube is only valid for generating lookup tables for CP.
-func fromCPCoordinate(c int) cube {
-	var result cube
+func fromCPCoordinate(c int) Cube {
+	var result Cube
 
 	available := make([]int, cornerCount)
 	for i := 0; i < cornerCount; i++ {
@@ -162,7 +182,7 @@ func fromCPCoordinate(c int) cube {
 // E slice edges and therefore has a problem size of 8!, just like CP.
 // This problem space is undefined in Phase 1 because the 8 UD edges might not
 // all be in the UD layers yet.
-func toUDCoordinate(c cube) int {
+func toUDCoordinate(c Cube) int {
 	result := 0
 	for i := 0; i < edgeFL; i++ {
 		count := 0
@@ -178,8 +198,8 @@ func toUDCoordinate(c cube) int {
 
 // fromUDCoordinate converts a coordinate in the UD space into a cube.
 // This cube is only valid for generating pruning tables for UD
-func fromUDCoordinate(c int) cube {
-	var result cube
+func fromUDCoordinate(c int) Cube {
+	var result Cube
 
 	available := make([]int, edgeFL)
 	for i := 0; i < edgeFL; i++ {
@@ -203,7 +223,7 @@ func fromUDCoordinate(c int) cube {
 // there are only 4! permutations of this space! This problem space
 // is undefined in Phase 1 because all 4 edges may not yet be in the
 // E slice.
-func toESliceP2Coordinate(c cube) int {
+func toESliceP2Coordinate(c Cube) int {
 	result := 0
 	for i := 0; i < 4; i++ {
 		count := 0
@@ -220,8 +240,8 @@ func toESliceP2Coordinate(c cube) int {
 // fromESliceP2Coordinate converts a coordinate into a Cube. This
 // coordinate is only valid for generating pruning tables for the
 // phase 2 E slice problem space.
-func fromESliceP2Coordinate(c int) cube {
-	var result cube
+func fromESliceP2Coordinate(c int) Cube {
+	var result Cube
 
 	available := make([]int, 4)
 	for i := 0; i < 4; i++ {
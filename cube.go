@@ -1,34 +1,121 @@
 package cube
 
-type cube = struct {
-	Edges   [edgeCount]piece
-	Corners [cornerCount]piece
+// Cube is the state of a Rubik's Cube in the cubie model. Each entry
+// of Edges and Corners is a position on the cube and holds the piece
+// currently sitting in that position along with its orientation.
+// The zero value is not a valid cube, use Solved to get started.
+type Cube struct {
+	Edges   [edgeCount]Piece
+	Corners [cornerCount]Piece
 }
 
-type piece = struct {
+// Piece is a single edge or corner. Index identifies the piece by its
+// home position and Orientation is its twist relative to that home,
+// 0-1 for edges and 0-2 for corners.
+type Piece struct {
 	Index       int
 	Orientation int
 }
 
+// Solved returns a solved cube.
+func Solved() Cube {
+	return cubeSolved
+}
+
+// FromScramble returns the cube produced by applying the given scramble
+// to a solved cube. The scramble can use slice turns, wide turns and
+// rotations as well as face turns. Like every Cube, the result is
+// relative to the centers, so it's as if the cube was turned back to
+// the way it was held at the start. If the scramble can't be parsed the
+// error is a *ParseError.
+func FromScramble(scramble string) (Cube, error) {
+	return parseScrambe(scramble)
+}
+
+// Apply returns the cube after performing a single move.
+func (c Cube) Apply(m Move) Cube {
+	return transform(c, moves[m])
+}
+
+// ApplySequence returns the cube after performing each move in order.
+func (c Cube) ApplySequence(ms []Move) Cube {
+	for _, m := range ms {
+		c = c.Apply(m)
+	}
+	return c
+}
+
+// InvertMoves returns the sequence of moves which undoes ms: the same
+// moves in reverse order with each one turned the other way.
+func InvertMoves(ms []Move) []Move {
+	result := make([]Move, len(ms))
+	for i, m := range ms {
+		result[len(ms)-1-i] = m/3*3 + 2 - m%3
+	}
+	return result
+}
+
+// Compose returns the cube reached by performing the moves that
+// produce other, starting from c instead of from a solved cube.
+func (c Cube) Compose(other Cube) Cube {
+	return transform(c, other)
+}
+
+// Inverse returns the cube which, composed with c, results in a solved
+// cube. Its scramble is the inverse of c's scramble.
+func (c Cube) Inverse() Cube {
+	var result Cube
+	for i, e := range c.Edges {
+		result.Edges[e.Index] = Piece{
+			Index:       i,
+			Orientation: (2 - e.Orientation) % 2,
+		}
+	}
+	for i, corner := range c.Corners {
+		result.Corners[corner.Index] = Piece{
+			Index:       i,
+			Orientation: (3 - corner.Orientation) % 3,
+		}
+	}
+	return result
+}
+
+// Equal reports whether two cubes are in the same state.
+func (c Cube) Equal(other Cube) bool {
+	return c == other
+}
+
+// IsSolved reports whether the cube is solved.
+func (c Cube) IsSolved() bool {
+	return c == cubeSolved
+}
+
 // transform applies a permutation and orientation to a cube.
 // Defining the transform we'd like to apply is actually the same
 // shape as defining the current state of a cube. You can think
 // of the current state of the cube a as a tranform of the solved cube
-func transform(a, b cube) cube {
-	var result cube
+func transform(a, b Cube) Cube {
+	var result Cube
 	for i, tEdge := range b.Edges {
 		cEdge := a.Edges[tEdge.Index]
-		result.Edges[i] = piece{
+		result.Edges[i] = Piece{
 			Index:       cEdge.Index,
 			Orientation: (cEdge.Orientation + tEdge.Orientation) % 2,
 		}
 	}
 	for i, tCorner := range b.Corners {
 		cCorner := a.Corners[tCorner.Index]
-		result.Corners[i] = piece{
+		result.Corners[i] = Piece{
 			Index:       cCorner.Index,
 			Orientation: (cCorner.Orientation + tCorner.Orientation) % 3,
 		}
 	}
 	return result
 }
+
+// conjugate returns s⁻¹·c·s, the cube c as seen after the whole cube
+// rotation s has been applied. Every move of c corresponds to a move of
+// the conjugate, so both are the same distance from solved.
+func conjugate(c, s Cube) Cube {
+	return transform(transform(s.Inverse(), c), s)
+}
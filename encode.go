@@ -1,75 +1,521 @@
 package cube
 
 import (
-	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-func parseScrambe(s string) (cube, error) {
-	movesMap := map[string]int{
-		"U":  moveU,
-		"U2": moveU2,
-		"U'": moveU3,
-		"L":  moveL,
-		"L2": moveL2,
-		"L'": moveL3,
-		"F":  moveF,
-		"F2": moveF2,
-		"F'": moveF3,
-		"R":  moveR,
-		"R2": moveR2,
-		"R'": moveR3,
-		"B":  moveB,
-		"B2": moveB2,
-		"B'": moveB3,
-		"D":  moveD,
-		"D2": moveD2,
-		"D'": moveD3,
-	}
-	s = strings.ReplaceAll(s, " ", "")
-	result := cubeSolved
+var moveStrings = [moveCount]string{
+	"U",
+	"U2",
+	"U'",
+	"L",
+	"L2",
+	"L'",
+	"F",
+	"F2",
+	"F'",
+	"R",
+	"R2",
+	"R'",
+	"B",
+	"B2",
+	"B'",
+	"D",
+	"D2",
+	"D'",
+}
 
-	for i := 0; i < len(s); i++ {
-		if i+1 < len(s) && (s[i+1] == '2' || s[i+1] == '\'') {
-			if move, exists := movesMap[s[i:i+2]]; exists {
-				result = transform(result, moves[move])
-				i++
-			}
-		} else if move, exists := movesMap[string(s[i])]; exists {
-			result = transform(result, moves[move])
+// String returns the move in standard notation, e.g. "R'".
+func (m Move) String() string {
+	if m < 0 || m >= moveCount {
+		return "?"
+	}
+	return moveStrings[m]
+}
 
-		} else {
-			return cubeSolved, errors.New("invalid scamble")
-		}
+// ParseMoves parses a sequence of moves written in standard notation,
+// e.g. "R U R' U'". Whitespace between moves is optional. Slice turns,
+// wide turns and rotations are translated into face turns, e.g. "M" is
+// returned as R L', see parseMoves. If s can't be parsed the error is a
+// *ParseError saying where.
+func ParseMoves(s string) ([]Move, error) {
+	path, err := parseMoves(s)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Move, len(path))
+	for i, m := range path {
+		result[i] = Move(m)
 	}
 	return result, nil
 }
 
-func toString(moves []int) string {
-	moveStrings := [moveCount]string{
-		"U",
-		"U2",
-		"U'",
-		"L",
-		"L2",
-		"L'",
-		"F",
-		"F2",
-		"F'",
-		"R",
-		"R2",
-		"R'",
-		"B",
-		"B2",
-		"B'",
-		"D",
-		"D2",
-		"D'",
+// FormatMoves writes a sequence of moves in standard notation, separated
+// by spaces. It is the reverse of ParseMoves.
+func FormatMoves(ms []Move) string {
+	var b strings.Builder
+	for i, m := range ms {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(m.String())
 	}
+	return b.String()
+}
+
+// FormatSiGN writes a sequence of moves in SiGN notation, turning every
+// pair of face turns that make up a slice turn, e.g. R L', back into the
+// slice turn. Slice turns move the centers, so the face turns after one
+// are written for wherever their faces have ended up. ParseMoves reads
+// the result back into the same moves, give or take the order of the
+// face turns in each pair.
+func FormatSiGN(ms []Move) string {
 	var b strings.Builder
-	for i := 0; i < len(moves); i++ {
-		b.WriteString(moveStrings[moves[i]])
-		b.WriteString(" ")
+	f := startFrame
+	for i := 0; i < len(ms); i++ {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		want := []int{int(ms[i])}
+		names := faceLetters
+		if i+1 < len(ms) && isSliceTurn(ms[i], ms[i+1]) {
+			want = append(want, int(ms[i+1]))
+			names = "MES"
+			i++
+		}
+		b.WriteString(f.find(names, want))
 	}
 	return b.String()
 }
+
+// find returns the move, out of the single letter moves in names and
+// their variants, that turns the same faces as want in this frame, and
+// moves the frame on past it.
+func (f *frame) find(names string, want []int) string {
+	for _, name := range names {
+		move := notationMoves[string(name)]
+		for amount := 1; amount < 4; amount++ {
+			next := *f
+			if sameTurns(next.apply(nil, move, amount), want) {
+				*f = next
+				return string(name) + [4]string{"", "", "2", "'"}[amount]
+			}
+		}
+	}
+	// every face turn and slice turn has a name in every frame
+	return "?"
+}
+
+// sameTurns reports whether a and b are the same one or two face turns,
+// in either order.
+func sameTurns(a, b []int) bool {
+	switch {
+	case len(a) != len(b):
+		return false
+	case len(a) == 1:
+		return a[0] == b[0]
+	}
+	return a[0] == b[0] && a[1] == b[1] || a[0] == b[1] && a[1] == b[0]
+}
+
+func parseScrambe(s string) (Cube, error) {
+	path, err := parseMoves(s)
+	if err != nil {
+		return cubeSolved, err
+	}
+	result := cubeSolved
+	for _, move := range path {
+		result = transform(result, moves[move])
+	}
+	return result, nil
+}
+
+// Besides the 18 face turns, scrambles and algorithms can use:
+//
+//   - slice turns M, E and S, which turn the middle layer in the same
+//     direction as L, D and F
+//   - wide turns Rw, Uw, ... or r, u, ..., which turn a face together
+//     with the middle layer next to it
+//   - whole cube rotations x, y and z, which turn the cube the same way
+//     as R, U and F
+//
+// all with the usual 2 and ' suffixes. The cubie model has no centers,
+// it keeps track of the pieces relative to wherever the centers are. A
+// slice turn is the same as turning the two faces either side of it and
+// then rotating the whole cube so that the centers are back where they
+// were, e.g. M = R L' x', and a wide turn is the same as turning the
+// opposite face and rotating, e.g. Rw = L x. So rotations are the only
+// thing which moves the centers, and all they do is change which face
+// of the cube is on which side. After an x, the face on top is the one
+// that used to be in front, and U turns it.
+//
+// parseMoves keeps track of this frame of reference as it goes, and
+// turns everything into face turns of the cube as it was first held. The
+// resulting cube is the same relative to its centers, and its solutions
+// are for the cube held that way, with the starting U face on top.
+
+// faceLetters are the faces, in move order, as written in notation
+const faceLetters = "ULFRBD"
+
+// rotationCycles are the sides of the cube each rotation moves through,
+// in the order a face travels around them: x takes the front face up,
+// y takes it to the left and z takes the top face to the right.
+var rotationCycles = [3][4]int{
+	{sideF, sideU, sideB, sideD},
+	{sideF, sideL, sideB, sideR},
+	{sideU, sideR, sideD, sideL},
+}
+
+// sideF and friends are faces in move order, see faceLetters
+const (
+	sideU = iota
+	sideL
+	sideF
+	sideR
+	sideB
+	sideD
+)
+
+// notationMove is one move of extended notation, as face turns of the
+// sides of the cube followed by a rotation of the whole cube, all for a
+// single clockwise quarter turn.
+type notationMove struct {
+	// turns are face and amount pairs, the amount being 1 for a quarter
+	// turn and 3 for a counter clockwise quarter turn
+	turns [][2]int
+	// axis is the rotation, 0-2 for x, y and z, or -1 for none
+	axis int
+	// rotation is the number of quarter turns of the rotation
+	rotation int
+}
+
+var notationMoves = withFaceTurns(map[string]notationMove{
+	"M":  {turns: [][2]int{{sideR, 1}, {sideL, 3}}, axis: 0, rotation: 3},
+	"E":  {turns: [][2]int{{sideU, 1}, {sideD, 3}}, axis: 1, rotation: 3},
+	"S":  {turns: [][2]int{{sideF, 3}, {sideB, 1}}, axis: 2, rotation: 1},
+	"Rw": {turns: [][2]int{{sideL, 1}}, axis: 0, rotation: 1},
+	"Lw": {turns: [][2]int{{sideR, 1}}, axis: 0, rotation: 3},
+	"Uw": {turns: [][2]int{{sideD, 1}}, axis: 1, rotation: 1},
+	"Dw": {turns: [][2]int{{sideU, 1}}, axis: 1, rotation: 3},
+	"Fw": {turns: [][2]int{{sideB, 1}}, axis: 2, rotation: 1},
+	"Bw": {turns: [][2]int{{sideF, 1}}, axis: 2, rotation: 3},
+	"x":  {axis: 0, rotation: 1},
+	"y":  {axis: 1, rotation: 1},
+	"z":  {axis: 2, rotation: 1},
+})
+
+// withFaceTurns adds the face turns, and the lower case names of the
+// wide turns, to the other moves of extended notation.
+func withFaceTurns(notationMoves map[string]notationMove) map[string]notationMove {
+	for i := 0; i < len(faceLetters); i++ {
+		name := faceLetters[i : i+1]
+		notationMoves[name] = notationMove{turns: [][2]int{{i, 1}}, axis: -1}
+		// r is the same as Rw and so on
+		notationMoves[strings.ToLower(name)] = notationMoves[name+"w"]
+	}
+	return notationMoves
+}
+
+// frame maps each side of the cube, in move order, to the face of the
+// cube as it was first held which is now on that side.
+type frame [6]int
+
+var startFrame = frame{sideU, sideL, sideF, sideR, sideB, sideD}
+
+// apply adds the face turns that make up a move turned amount quarter
+// turns to path, and updates the frame for any rotation.
+func (f *frame) apply(path []int, move notationMove, amount int) []int {
+	for _, turn := range move.turns {
+		face, turnAmount := f[turn[0]], turn[1]*amount%4
+		path = append(path, face*3+turnAmount-1)
+	}
+	if move.axis >= 0 {
+		cycle := rotationCycles[move.axis]
+		for n := 0; n < move.rotation*amount%4; n++ {
+			var next frame = *f
+			for i := range cycle {
+				next[cycle[(i+1)%4]] = f[cycle[i]]
+			}
+			*f = next
+		}
+	}
+	return path
+}
+
+// Moves can be grouped to repeat or invert them all at once, and
+// commutators and conjugates can be written with brackets:
+//
+//	sequence = { item }
+//	item     = ( move | "(" sequence ")" | bracket ) [ count ] [ "'" ]
+//	bracket  = "[" sequence ( "," | ":" ) sequence "]"
+//
+// A count repeats the item and a prime inverts it, e.g. (R U R' U')3 or
+// (R U)'. For a single move these are just the usual suffixes, so R2' is
+// a half turn. [A, B] is the commutator A B A' B' and [A: B] is the
+// conjugate A B A'. Whitespace is ignored, and // starts a comment which
+// runs to the end of the line.
+//
+// parseMoves expands all of this into a flat list of turns before
+// translating them into face turns, so that inverting or repeating a
+// group which contains rotations works out.
+
+// maxSequenceLength limits how long a sequence can get once repetitions
+// are expanded, so that something like (R U)999999999 can't use up all
+// the memory.
+const maxSequenceLength = 10000
+
+// turn is a move of extended notation turned amount quarter turns, 1-3.
+type turn struct {
+	move   notationMove
+	amount int
+}
+
+// invertTurns returns the turns which undo turns.
+func invertTurns(turns []turn) []turn {
+	result := make([]turn, len(turns))
+	for i, t := range turns {
+		result[len(turns)-1-i] = turn{move: t.move, amount: 4 - t.amount}
+	}
+	return result
+}
+
+// moveParser is a recursive descent parser over the grammar above.
+type moveParser struct {
+	s   string
+	pos int
+}
+
+func parseMoves(s string) ([]int, error) {
+	p := moveParser{s: s}
+	turns, err := p.sequence()
+	if err != nil {
+		return nil, err
+	}
+	// sequence stops at anything which can't start an item, which is
+	// only allowed inside brackets
+	if p.pos < len(p.s) {
+		return nil, p.fail("a move")
+	}
+
+	result := make([]int, 0)
+	f := startFrame
+	for _, t := range turns {
+		result = f.apply(result, t.move, t.amount)
+	}
+	return result, nil
+}
+
+// ParseError describes where and why a sequence of moves couldn't be
+// parsed.
+type ParseError struct {
+	// Token is the text which couldn't be parsed, or empty if the input
+	// ended too soon
+	Token string
+	// Offset is the byte offset of Token in the input
+	Offset int
+	// Expected describes what should have been there instead
+	Expected string
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("invalid scramble: unexpected end at offset %d, expected %s", e.Offset, e.Expected)
+	}
+	return fmt.Sprintf("invalid scramble: unexpected %q at offset %d, expected %s", e.Token, e.Offset, e.Expected)
+}
+
+// punctuation are the characters of the grammar other than moves
+const punctuation = "()[],:"
+
+func (p *moveParser) fail(expected string) error {
+	return &ParseError{Token: p.token(), Offset: p.pos, Expected: expected}
+}
+
+// token returns the text at the current position for error messages:
+// a single punctuation character, or everything up to the next space or
+// punctuation so that a mistyped move is shown whole.
+func (p *moveParser) token() string {
+	end := p.pos
+	for end < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[end:])
+		if unicode.IsSpace(r) || strings.ContainsRune(punctuation, r) {
+			if end == p.pos && !unicode.IsSpace(r) {
+				end += size
+			}
+			break
+		}
+		end += size
+	}
+	return p.s[p.pos:end]
+}
+
+// skipSpace moves past whitespace and comments.
+func (p *moveParser) skipSpace() {
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], "//"):
+			end := strings.IndexByte(p.s[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.s)
+			} else {
+				p.pos += end
+			}
+		case unicode.IsSpace(r):
+			p.pos += size
+		default:
+			return
+		}
+	}
+}
+
+// primes are the characters accepted for a prime. Text copied from web
+// pages often has a typographic apostrophe or a prime symbol instead of
+// a plain apostrophe.
+var primes = []string{"'", "\u2019", "\u2032"}
+
+// prime moves past a prime if there is one next.
+func (p *moveParser) prime() bool {
+	for _, prime := range primes {
+		if strings.HasPrefix(p.s[p.pos:], prime) {
+			p.pos += len(prime)
+			return true
+		}
+	}
+	return false
+}
+
+// consume moves past the next character if it is c.
+func (p *moveParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *moveParser) sequence() ([]turn, error) {
+	var result []turn
+	for {
+		p.skipSpace()
+		if p.pos == len(p.s) || strings.IndexByte(")],:", p.s[p.pos]) >= 0 {
+			return result, nil
+		}
+		start := p.pos
+		item, err := p.item()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item...)
+		if len(result) > maxSequenceLength {
+			return nil, p.tooLong(start)
+		}
+	}
+}
+
+// tooLong reports that the item starting at start made the sequence
+// longer than maxSequenceLength.
+func (p *moveParser) tooLong(start int) error {
+	return &ParseError{
+		Token:    p.s[start:p.pos],
+		Offset:   start,
+		Expected: fmt.Sprintf("no more than %d moves in total", maxSequenceLength),
+	}
+}
+
+func (p *moveParser) item() ([]turn, error) {
+	start := p.pos
+	var turns []turn
+	switch {
+	case p.consume('('):
+		inner, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(')') {
+			return nil, p.fail(`")"`)
+		}
+		turns = inner
+	case p.consume('['):
+		a, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		commutator := p.consume(',')
+		if !commutator && !p.consume(':') {
+			return nil, p.fail(`"," or ":"`)
+		}
+		b, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(']') {
+			return nil, p.fail(`"]"`)
+		}
+		turns = append(append(a, b...), invertTurns(a)...)
+		if commutator {
+			turns = append(turns, invertTurns(b)...)
+		}
+	default:
+		move, err := p.move()
+		if err != nil {
+			return nil, err
+		}
+		turns = []turn{{move: move, amount: 1}}
+	}
+
+	// the count and prime can be spaced out from the item, as they could
+	// before groups were added, so R 2 is still a half turn
+	p.skipSpace()
+	count := 1
+	if digits := p.pos; p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		var err error
+		if count, err = strconv.Atoi(p.s[digits:p.pos]); err != nil || count > maxSequenceLength || count*len(turns) > maxSequenceLength {
+			return nil, p.tooLong(start)
+		}
+		p.skipSpace()
+	}
+	inverse := p.prime()
+
+	if len(turns) == 1 {
+		// a single move is turned rather than repeated, so R2 stays one
+		// half turn
+		amount := turns[0].amount * count % 4
+		if amount == 0 {
+			return nil, nil
+		}
+		turns[0].amount = amount
+	} else {
+		repeated := make([]turn, 0, len(turns)*count)
+		for i := 0; i < count; i++ {
+			repeated = append(repeated, turns...)
+		}
+		turns = repeated
+	}
+	if inverse {
+		turns = invertTurns(turns)
+	}
+	return turns, nil
+}
+
+// move parses a single move without its suffix.
+func (p *moveParser) move() (notationMove, error) {
+	name := p.s[p.pos : p.pos+1]
+	if p.pos+1 < len(p.s) && p.s[p.pos+1] == 'w' && strings.Contains(faceLetters, name) {
+		name += "w"
+	}
+	move, exists := notationMoves[name]
+	if !exists {
+		return notationMove{}, p.fail("a move")
+	}
+	p.pos += len(name)
+	return move, nil
+}
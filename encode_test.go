@@ -0,0 +1,89 @@
+package cube
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoves(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"R U R' U'", "R U R' U'"},
+		{"RUR'U'", "R U R' U'"},
+		{"R2' U3 F4 D5", "R2 U' D"},
+		{"R 2 U '", "R2 U'"},
+		{"R’ U′", "R' U'"},
+		{"R U // sexy move\nF", "R U F"},
+
+		// rotations change which face each letter turns
+		{"x U", "F"},
+		{"x' U", "B"},
+		{"y R", "B"},
+		{"z U", "L"},
+		{"x2 y U", "D"},
+		{"x", ""},
+
+		// slice and wide turns are face turns and a rotation
+		{"M", "R L'"},
+		{"M U", "R L' B"},
+		{"E2 F", "U2 D2 B"},
+		{"S R", "F' B U"},
+		{"Rw U", "L F"},
+		{"r U", "L F"},
+		{"r' u2", "L' F2"},
+
+		// groups, commutators and conjugates
+		{"(R U)2", "R U R U"},
+		{"(R U)'", "U' R'"},
+		{"(R U)2'", "U' R' U' R'"},
+		{"(R U) 2", "R U R U"},
+		{"(x R)' U", "R' B"},
+		{"[R, U]", "R U R' U'"},
+		{"[R: U]", "R U R'"},
+		{"[F: [R, U]]", "F R U R' U' F'"},
+		{"[R U R', D]2", "R U R' D R U' R' D' R U R' D R U' R' D'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			moves, err := ParseMoves(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := FormatMoves(moves); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMovesErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		token    string
+		offset   int
+		expected string
+	}{
+		{"R X U", "X", 2, "a move"},
+		{"R Uw2 Q'", "Q'", 6, "a move"},
+		{"R U)", ")", 3, "a move"},
+		{"(R U", "", 4, `")"`},
+		{"[R U]", "]", 4, `"," or ":"`},
+		{"[R, U", "", 5, `"]"`},
+		{"(R U)99999", "(R U)99999", 0, "no more than 10000 moves in total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseMoves(tt.input)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("got %v, want a *ParseError", err)
+			}
+			if parseErr.Token != tt.token || parseErr.Offset != tt.offset || parseErr.Expected != tt.expected {
+				t.Errorf("got %q at %d expecting %s, want %q at %d expecting %s",
+					parseErr.Token, parseErr.Offset, parseErr.Expected, tt.token, tt.offset, tt.expected)
+			}
+		})
+	}
+}
@@ -1,5 +1,31 @@
 package cube
 
+// Move is a single turn of one of the six faces.
+type Move int
+
+// The 18 face turns of the half turn metric. A 3 suffix is a counter
+// clockwise quarter turn, written with a prime (e.g. U') in notation.
+const (
+	MoveU  Move = moveU
+	MoveU2 Move = moveU2
+	MoveU3 Move = moveU3
+	MoveL  Move = moveL
+	MoveL2 Move = moveL2
+	MoveL3 Move = moveL3
+	MoveF  Move = moveF
+	MoveF2 Move = moveF2
+	MoveF3 Move = moveF3
+	MoveR  Move = moveR
+	MoveR2 Move = moveR2
+	MoveR3 Move = moveR3
+	MoveB  Move = moveB
+	MoveB2 Move = moveB2
+	MoveB3 Move = moveB3
+	MoveD  Move = moveD
+	MoveD2 Move = moveD2
+	MoveD3 Move = moveD3
+)
+
 const (
 	moveU = iota
 	moveU2
@@ -23,6 +49,23 @@ const (
 	moveCount
 )
 
+// The slice turns are only searched in the slice turn metric, and never
+// appear in a Move. Like the face turns, a 3 suffix is a counter
+// clockwise quarter turn.
+const (
+	moveM = moveCount + iota
+	moveM2
+	moveM3
+	moveE
+	moveE2
+	moveE3
+	moveS
+	moveS2
+	moveS3
+
+	searchMoveCount
+)
+
 const (
 	edgeUB = iota
 	edgeUR
@@ -0,0 +1,257 @@
+package cube
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A facelet string lists the 54 stickers of a cube face by face in the
+// order U, R, F, D, L, B. Each face is read row by row, left to right,
+// as it looks when viewed straight on in the usual cube net:
+//
+//	            U1 U2 U3
+//	            U4 U5 U6
+//	            U7 U8 U9
+//	L1 L2 L3    F1 F2 F3    R1 R2 R3    B1 B2 B3
+//	L4 L5 L6    F4 F5 F6    R4 R5 R6    B4 B5 B6
+//	L7 L8 L9    F7 F8 F9    R7 R8 R9    B7 B8 B9
+//	            D1 D2 D3
+//	            D4 D5 D6
+//	            D7 D8 D9
+//
+// This is the same format used by Kociemba's Cube Explorer, so the
+// solved cube is "UUUUUUUUURRRRRRRRRFFFFFFFFFDDDDDDDDDLLLLLLLLLBBBBBBBBB".
+const faceletCount = 54
+
+const (
+	faceU = iota
+	faceR
+	faceF
+	faceD
+	faceL
+	faceB
+
+	faceCount
+)
+
+const faceNames = "URFDLB"
+
+// The reasons a facelet string can fail to convert. These are wrapped in
+// a *FaceletError, so check for them with errors.Is.
+var (
+	ErrFaceletCount     = errors.New("wrong number of facelets")
+	ErrSameCenters      = errors.New("two centers are the same color")
+	ErrUnknownColor     = errors.New("facelet does not match any center")
+	ErrImpossibleCorner = errors.New("corner has impossible colors")
+	ErrImpossibleEdge   = errors.New("edge has impossible colors")
+)
+
+// FaceletError describes why a facelet string isn't a cube.
+type FaceletError struct {
+	// Err is one of the Err* reasons above, possibly wrapped with more
+	// detail
+	Err error
+	// Piece names the offending piece, e.g. "UF" or "DRF", or facelet,
+	// e.g. "R4", when the problem is with a single one
+	Piece string
+}
+
+func (e *FaceletError) Error() string {
+	if e.Piece != "" {
+		return fmt.Sprintf("facelets: %s: %v", e.Piece, e.Err)
+	}
+	return fmt.Sprintf("facelets: %v", e.Err)
+}
+
+func (e *FaceletError) Unwrap() error {
+	return e.Err
+}
+
+// faceletName names a facelet by its face and its number on the face,
+// as in the net above, e.g. "R4".
+func faceletName(i int) string {
+	return fmt.Sprintf("%c%d", faceNames[i/9], i%9+1)
+}
+
+// cornerFacelets holds the facelets of each corner position. The first
+// facelet is always the U or D sticker, and the other two follow clockwise,
+// which matches the direction corner orientation is counted in.
+var cornerFacelets = [cornerCount][3]int{
+	{0, 36, 47},  // UBL: U1 L1 B3
+	{2, 45, 11},  // URB: U3 B1 R3
+	{8, 9, 20},   // UFR: U9 R1 F3
+	{6, 18, 38},  // ULF: U7 F1 L3
+	{27, 44, 24}, // DFL: D1 L9 F7
+	{29, 26, 15}, // DRF: D3 F9 R7
+	{35, 17, 51}, // DBR: D9 R9 B7
+	{33, 53, 42}, // DLB: D7 B9 L7
+}
+
+// cornerColors are the faces of each corner piece, in the same order
+// as its stickers appear in cornerFacelets when the corner is solved.
+var cornerColors = [cornerCount][3]int{
+	{faceU, faceL, faceB},
+	{faceU, faceB, faceR},
+	{faceU, faceR, faceF},
+	{faceU, faceF, faceL},
+	{faceD, faceL, faceF},
+	{faceD, faceF, faceR},
+	{faceD, faceR, faceB},
+	{faceD, faceB, faceL},
+}
+
+// edgeFacelets holds the facelets of each edge position. The first
+// facelet is the U or D sticker, or F or B for the E slice edges,
+// which is the sticker edge orientation is measured against.
+var edgeFacelets = [edgeCount][2]int{
+	{1, 46},  // UB: U2 B2
+	{5, 10},  // UR: U6 R2
+	{7, 19},  // UF: U8 F2
+	{3, 37},  // UL: U4 L2
+	{28, 25}, // DF: D2 F8
+	{32, 16}, // DR: D6 R8
+	{34, 52}, // DB: D8 B8
+	{30, 43}, // DL: D4 L8
+	{21, 41}, // FL: F4 L6
+	{23, 12}, // FR: F6 R4
+	{48, 14}, // BR: B4 R6
+	{50, 39}, // BL: B6 L4
+}
+
+// edgeColors are the faces of each edge piece, in the same order as
+// its stickers appear in edgeFacelets when the edge is solved.
+var edgeColors = [edgeCount][2]int{
+	{faceU, faceB},
+	{faceU, faceR},
+	{faceU, faceF},
+	{faceU, faceL},
+	{faceD, faceF},
+	{faceD, faceR},
+	{faceD, faceB},
+	{faceD, faceL},
+	{faceF, faceL},
+	{faceF, faceR},
+	{faceB, faceR},
+	{faceB, faceL},
+}
+
+// FromFacelets converts a 54 character facelet string into a Cube.
+// The characters don't need to be the face letters URFDLB; any six
+// distinct characters work, and the character on each center decides
+// which face it stands for. This allows a cube to be read off by its
+// colors, e.g. "WWWWWWWWWRRR...". Whitespace is ignored. Facelets that
+// can't be a cube are rejected with a *FaceletError, and they can still
+// make an unsolvable cube, see Validate.
+func FromFacelets(s string) (Cube, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s) != faceletCount {
+		return cubeSolved, &FaceletError{Err: fmt.Errorf("%w: expected %d, got %d", ErrFaceletCount, faceletCount, len(s))}
+	}
+
+	// the center of each face determines which face each character names
+	var faceOf [256]int
+	for i := range faceOf {
+		faceOf[i] = -1
+	}
+	for f := 0; f < faceCount; f++ {
+		center := s[f*9+4]
+		if faceOf[center] != -1 {
+			return cubeSolved, &FaceletError{Err: fmt.Errorf("%w: %c and %c", ErrSameCenters, faceNames[faceOf[center]], faceNames[f])}
+		}
+		faceOf[center] = f
+	}
+
+	var facelets [faceletCount]int
+	for i := 0; i < faceletCount; i++ {
+		facelets[i] = faceOf[s[i]]
+		if facelets[i] == -1 {
+			return cubeSolved, &FaceletError{Err: fmt.Errorf("%w: %q", ErrUnknownColor, s[i]), Piece: faceletName(i)}
+		}
+	}
+
+	var result Cube
+	for i, positions := range cornerFacelets {
+		// the orientation is whichever sticker holds the U or D color
+		orientation := 0
+		for orientation < 3 {
+			f := facelets[positions[orientation]]
+			if f == faceU || f == faceD {
+				break
+			}
+			orientation++
+		}
+		if orientation == 3 {
+			return cubeSolved, &FaceletError{Err: fmt.Errorf("%w: no U or D sticker", ErrImpossibleCorner), Piece: cornerName(i)}
+		}
+		ud := facelets[positions[orientation]]
+		a := facelets[positions[(orientation+1)%3]]
+		b := facelets[positions[(orientation+2)%3]]
+
+		found := false
+		for j, colors := range cornerColors {
+			if colors[0] == ud && colors[1] == a && colors[2] == b {
+				result.Corners[i] = Piece{Index: j, Orientation: orientation}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return cubeSolved, &FaceletError{Err: ErrImpossibleCorner, Piece: cornerName(i)}
+		}
+	}
+
+	for i, positions := range edgeFacelets {
+		a := facelets[positions[0]]
+		b := facelets[positions[1]]
+
+		found := false
+		for j, colors := range edgeColors {
+			if colors[0] == a && colors[1] == b {
+				result.Edges[i] = Piece{Index: j, Orientation: 0}
+				found = true
+				break
+			}
+			if colors[0] == b && colors[1] == a {
+				result.Edges[i] = Piece{Index: j, Orientation: 1}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return cubeSolved, &FaceletError{Err: ErrImpossibleEdge, Piece: edgeName(i)}
+		}
+	}
+
+	return result, nil
+}
+
+// Facelets converts the cube into a 54 character facelet string using
+// the face letters URFDLB. See FromFacelets for the layout.
+func (c Cube) Facelets() string {
+	var facelets [faceletCount]byte
+	for f := 0; f < faceCount; f++ {
+		facelets[f*9+4] = faceNames[f]
+	}
+	for i, corner := range c.Corners {
+		for n := 0; n < 3; n++ {
+			position := cornerFacelets[i][(n+corner.Orientation)%3]
+			facelets[position] = faceNames[cornerColors[corner.Index][n]]
+		}
+	}
+	for i, edge := range c.Edges {
+		for n := 0; n < 2; n++ {
+			position := edgeFacelets[i][(n+edge.Orientation)%2]
+			facelets[position] = faceNames[edgeColors[edge.Index][n]]
+		}
+	}
+	return string(facelets[:])
+}
+
+func cornerName(i int) string {
+	return [cornerCount]string{"UBL", "URB", "UFR", "ULF", "DFL", "DRF", "DBR", "DLB"}[i]
+}
+
+func edgeName(i int) string {
+	return [edgeCount]string{"UB", "UR", "UF", "UL", "DF", "DR", "DB", "DL", "FL", "FR", "BR", "BL"}[i]
+}
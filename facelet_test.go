@@ -0,0 +1,102 @@
+package cube
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const solvedFacelets = "UUUUUUUUURRRRRRRRRFFFFFFFFFDDDDDDDDDLLLLLLLLLBBBBBBBBB"
+
+func TestFacelets(t *testing.T) {
+	// the cubes after one move, as written by Cube Explorer
+	tests := []struct {
+		name     string
+		cube     Cube
+		facelets string
+	}{
+		{"solved", cubeSolved, solvedFacelets},
+		{"U", cubeSolved.Apply(MoveU), "UUUUUUUUUBBBRRRRRRRRRFFFFFFDDDDDDDDDFFFLLLLLLLLLBBBBBB"},
+		{"R", cubeSolved.Apply(MoveR), "UUFUUFUUFRRRRRRRRRFFDFFDFFDDDBDDBDDBLLLLLLLLLUBBUBBUBB"},
+		{"F", cubeSolved.Apply(MoveF), "UUUUUULLLURRURRURRFFFFFFFFFRRRDDDDDDLLDLLDLLDBBBBBBBBB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cube.Facelets(); got != tt.facelets {
+				t.Errorf("Facelets() = %s, want %s", got, tt.facelets)
+			}
+			c, err := FromFacelets(tt.facelets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != tt.cube {
+				t.Errorf("FromFacelets(%s) = %v, want %v", tt.facelets, c, tt.cube)
+			}
+		})
+	}
+}
+
+func TestFaceletsColors(t *testing.T) {
+	colors := strings.NewReplacer("U", "W", "R", "R", "F", "G", "D", "Y", "L", "O", "B", "B")
+	want := cubeSolved.Apply(MoveR).Apply(MoveU)
+	c, err := FromFacelets(colors.Replace(want.Facelets()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != want {
+		t.Errorf("got %v, want %v", c, want)
+	}
+}
+
+func TestFaceletsRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		want := RandomCube(rng)
+		c, err := FromFacelets(want.Facelets())
+		if err != nil {
+			t.Fatalf("%s: %v", want.Facelets(), err)
+		}
+		if c != want {
+			t.Fatalf("%s: got %v, want %v", want.Facelets(), c, want)
+		}
+	}
+}
+
+func TestFromFaceletsErrors(t *testing.T) {
+	// recolor returns the solved facelets with the one at i changed to color
+	recolor := func(i int, color byte) string {
+		b := []byte(solvedFacelets)
+		b[i] = color
+		return string(b)
+	}
+	tests := []struct {
+		name     string
+		facelets string
+		err      error
+		piece    string
+	}{
+		{"short", solvedFacelets[1:], ErrFaceletCount, ""},
+		{"long", solvedFacelets + "U", ErrFaceletCount, ""},
+		{"same centers", recolor(13, 'U'), ErrSameCenters, ""},
+		{"unknown color", recolor(10, 'X'), ErrUnknownColor, "R2"},
+		{"corner without U or D", recolor(8, 'R'), ErrImpossibleCorner, "UFR"},
+		{"corner colors", recolor(9, 'L'), ErrImpossibleCorner, "UFR"},
+		{"edge colors", recolor(7, 'F'), ErrImpossibleEdge, "UF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromFacelets(tt.facelets)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			var faceletErr *FaceletError
+			if !errors.As(err, &faceletErr) {
+				t.Fatalf("%v is not a *FaceletError", err)
+			}
+			if faceletErr.Piece != tt.piece {
+				t.Errorf("piece is %q, want %q", faceletErr.Piece, tt.piece)
+			}
+		})
+	}
+}
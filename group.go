@@ -0,0 +1,151 @@
+package cube
+
+// Restricting the moves the solver can use raises two questions: can
+// the cube be solved at all with those moves, and does the two phase
+// search still work. Both come down to which cubes a set of moves can
+// reach, which is the group the moves generate. A permutation group is
+// described by a stabilizer chain, built here with the Schreier-Sims
+// algorithm, which can then test any cube for membership quickly.
+//
+// The cube is treated as a permutation of its 48 stickers, 2 for each
+// edge and 3 for each corner. A sticker is a piece position and a twist,
+// and the cube moves the sticker at position i with twist t to wherever
+// the piece in position i came from, with its twist added on.
+
+const stickerCount = 2*edgeCount + 3*cornerCount
+
+type perm [stickerCount]uint8
+
+var identityPerm = func() perm {
+	var result perm
+	for i := range result {
+		result[i] = uint8(i)
+	}
+	return result
+}()
+
+// toPerm returns the permutation of the stickers made by a cube. Like
+// transform, toPerm(transform(a, b)) is toPerm(a) after toPerm(b).
+func toPerm(c Cube) perm {
+	var result perm
+	for i, e := range c.Edges {
+		for t := 0; t < 2; t++ {
+			result[2*i+t] = uint8(2*e.Index + (t+e.Orientation)%2)
+		}
+	}
+	for i, corner := range c.Corners {
+		for t := 0; t < 3; t++ {
+			result[2*edgeCount+3*i+t] = uint8(2*edgeCount + 3*corner.Index + (t+corner.Orientation)%3)
+		}
+	}
+	return result
+}
+
+// compose returns a after b.
+func compose(a, b perm) perm {
+	var result perm
+	for i, x := range b {
+		result[i] = a[x]
+	}
+	return result
+}
+
+func (p perm) inverse() perm {
+	var result perm
+	for i, x := range p {
+		result[x] = uint8(i)
+	}
+	return result
+}
+
+// stabilizerLevel is one level of a stabilizer chain: the group which
+// fixes every base point of the levels above, along with the orbit of
+// this level's base point and a permutation taking the base point to
+// each point of the orbit.
+type stabilizerLevel struct {
+	base      uint8
+	gens      []perm
+	orbit     []uint8
+	transvers [stickerCount]*perm
+}
+
+// permGroup is the group generated by some cubes.
+type permGroup struct {
+	levels []*stabilizerLevel
+}
+
+func newPermGroup(gens []Cube) *permGroup {
+	g := &permGroup{}
+	for _, c := range gens {
+		g.insert(0, toPerm(c))
+	}
+	return g
+}
+
+// contains reports whether the group can reach cube c.
+func (g *permGroup) contains(c Cube) bool {
+	return g.sift(0, toPerm(c)) == identityPerm
+}
+
+// sift divides p by the transversals from level i down, and returns
+// what's left over, which is the identity if p is in the group.
+func (g *permGroup) sift(i int, p perm) perm {
+	for ; i < len(g.levels); i++ {
+		l := g.levels[i]
+		u := l.transvers[p[l.base]]
+		if u == nil {
+			break
+		}
+		p = compose(u.inverse(), p)
+	}
+	return p
+}
+
+// insert adds p to the group at level i, and every Schreier generator
+// that adding it makes to the levels below.
+func (g *permGroup) insert(i int, p perm) {
+	p = g.sift(i, p)
+	if p == identityPerm {
+		return
+	}
+	if i == len(g.levels) {
+		l := &stabilizerLevel{}
+		for x := range p {
+			if p[x] != uint8(x) {
+				l.base = uint8(x)
+				break
+			}
+		}
+		l.orbit = []uint8{l.base}
+		l.transvers[l.base] = &identityPerm
+		g.levels = append(g.levels, l)
+	}
+	l := g.levels[i]
+	l.gens = append(l.gens, p)
+	// the old points of the orbit have been through the old generators
+	// already, but any new point needs to go through all of them
+	old := len(l.orbit)
+	for j := 0; j < old; j++ {
+		g.extend(i, l.orbit[j], p)
+	}
+	for j := old; j < len(l.orbit); j++ {
+		for _, s := range l.gens {
+			g.extend(i, l.orbit[j], s)
+		}
+	}
+}
+
+// extend follows generator s from point x of level i's orbit. If it
+// leads out of the orbit the orbit grows, otherwise it's a Schreier
+// generator which belongs to the level below.
+func (g *permGroup) extend(i int, x uint8, s perm) {
+	l := g.levels[i]
+	t := compose(s, *l.transvers[x])
+	y := s[x]
+	if l.transvers[y] == nil {
+		l.transvers[y] = &t
+		l.orbit = append(l.orbit, y)
+		return
+	}
+	g.insert(i+1, compose(l.transvers[y].inverse(), t))
+}
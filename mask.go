@@ -0,0 +1,492 @@
+package cube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask marks the parts of the cube that a step of a human method, such
+// as the cross or orienting the last layer, has to solve. The rest of
+// the cube can end up anywhere. Like Cube, each entry is a position on
+// the cube.
+type Mask struct {
+	Edges   [edgeCount]PieceMask
+	Corners [cornerCount]PieceMask
+}
+
+// PieceMask marks what matters about one position.
+type PieceMask struct {
+	// Index is set when the position has to hold its own piece
+	Index bool
+	// Orientation is set when whichever piece is in the position has to
+	// be oriented. With Index, that's the position's own piece solved.
+	Orientation bool
+}
+
+// ParseMask returns the mask which solves the positions named in solve,
+// and orients whichever piece ends up in the positions named in orient.
+// Positions are separated by spaces and named by their faces, in any
+// order, e.g. "DF DFR".
+func ParseMask(solve, orient string) (Mask, error) {
+	var m Mask
+	for _, names := range []struct {
+		s     string
+		solve bool
+	}{{solve, true}, {orient, false}} {
+		for _, name := range strings.Fields(names.s) {
+			edges, corners := m.Edges[:], m.Corners[:]
+			var piece *PieceMask
+			for i := range edges {
+				if sameFaces(edgeName(i), name) {
+					piece = &edges[i]
+				}
+			}
+			for i := range corners {
+				if sameFaces(cornerName(i), name) {
+					piece = &corners[i]
+				}
+			}
+			if piece == nil {
+				return Mask{}, fmt.Errorf("unknown piece %q", name)
+			}
+			piece.Index = piece.Index || names.solve
+			piece.Orientation = true
+		}
+	}
+	return m, nil
+}
+
+// sameFaces reports whether two piece names have the same faces.
+func sameFaces(a, b string) bool {
+	sortFaces := func(s string) string {
+		faces := strings.Split(strings.ToUpper(s), "")
+		sort.Strings(faces)
+		return strings.Join(faces, "")
+	}
+	return sortFaces(a) == sortFaces(b)
+}
+
+const (
+	crossPieces = "DF DR DB DL"
+	f2lPieces   = crossPieces + " FL FR BR BL DFL DRF DBR DLB"
+	llPieces    = "UB UR UF UL UBL URB UFR ULF"
+)
+
+// masks for the steps of CFOP, solving the cross on D. Each includes the
+// steps before it, and a pair includes the cross.
+var (
+	crossMask = mustParseMask(crossPieces, "")
+	pairMasks = map[string]Mask{
+		"fl": mustParseMask(crossPieces+" FL DFL", ""),
+		"fr": mustParseMask(crossPieces+" FR DRF", ""),
+		"br": mustParseMask(crossPieces+" BR DBR", ""),
+		"bl": mustParseMask(crossPieces+" BL DLB", ""),
+	}
+	f2lMask = mustParseMask(f2lPieces, "")
+	ollMask = mustParseMask(f2lPieces, llPieces)
+)
+
+// mustParseMask is ParseMask for the masks built in, which can't fail.
+func mustParseMask(solve, orient string) Mask {
+	m, err := ParseMask(solve, orient)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// StepMask returns the mask for a step of CFOP, solving the cross on D,
+// by name: cross; fl, fr, br or bl, which solve the cross and the F2L
+// pair in that slot; or oll, which keeps F2L solved. The tables for the
+// whole of F2L aren't strong enough to solve it from a scramble in
+// reasonable time, so there's no step for it, and oll is only quick once
+// F2L is solved, see StepReady.
+func StepMask(step string) (Mask, error) {
+	step = strings.ToLower(step)
+	switch step {
+	case "cross":
+		return crossMask, nil
+	case "fl", "fr", "br", "bl":
+		return pairMasks[step], nil
+	case "oll":
+		return ollMask, nil
+	}
+	return Mask{}, fmt.Errorf("unknown step %q", step)
+}
+
+// StepReady reports whether c is ready for a step to be solved with
+// StepMask: the cross and the pairs always are, but oll needs F2L to be
+// solved already.
+func StepReady(step string, c Cube) bool {
+	if strings.EqualFold(step, "oll") {
+		return f2lMask.Solved(c)
+	}
+	return true
+}
+
+// Solved reports whether everything the mask marks is solved on c.
+func (m Mask) Solved(c Cube) bool {
+	for i, pm := range m.Edges {
+		if pm.Index && c.Edges[i].Index != i || pm.Orientation && c.Edges[i].Orientation != 0 {
+			return false
+		}
+	}
+	for i, pm := range m.Corners {
+		if pm.Index && c.Corners[i].Index != i || pm.Orientation && c.Corners[i].Orientation != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// A masked solve is an IDA* search, like the optimal solver, but with
+// pruning tables built for the mask the first time it's used. The pieces
+// the mask solves are tracked by position and orientation, 24 states
+// each, in chunks of up to maskChunkSize pieces with a table for each
+// chunk. Orientation is tracked with the EO and CO coordinates, and a
+// table each for the positions the mask orients. Every table is exact
+// for the pieces it covers, so the largest is a lower bound for the
+// whole mask, and 0 from all of them means the mask is solved. The chunk
+// tables take a few seconds each to build, so they're saved to the table
+// directory, named after their pieces.
+
+// maskChunkSize keeps chunk tables to 24^5 entries, around 8MB
+const maskChunkSize = 5
+
+// maskChunkCount is the most chunks there can be, for all 20 pieces
+const maskChunkCount = (edgeCount + cornerCount + maskChunkSize - 1) / maskChunkSize
+
+// pieceMoves gives the state, position*2+orientation for edges and
+// position*3+orientation for corners, that each move takes a piece in
+// each state to.
+var pieceMoves = func() (result [2][24][searchMoveCount]uint8) {
+	for m, mc := range searchMoves {
+		for to, e := range mc.Edges {
+			for o := 0; o < 2; o++ {
+				result[0][e.Index*2+o][m] = uint8(to*2 + (o+e.Orientation)%2)
+			}
+		}
+		for to, c := range mc.Corners {
+			for o := 0; o < 3; o++ {
+				result[1][c.Index*3+o][m] = uint8(to*3 + (o+c.Orientation)%3)
+			}
+		}
+	}
+	return result
+}()
+
+// maskPiece is a piece a mask solves: kind is 0 for edges and 1 for
+// corners, and goals are the states it's solved in.
+type maskPiece struct {
+	kind, index int
+	goals       []int
+}
+
+// name is the piece's name, followed by ~ if it can be in any
+// orientation.
+func (p maskPiece) name() string {
+	name := edgeName(p.index)
+	if p.kind == 1 {
+		name = cornerName(p.index)
+	}
+	if len(p.goals) > 1 {
+		name += "~"
+	}
+	return name
+}
+
+type maskChunk struct {
+	pieces []maskPiece
+	table  []byte
+}
+
+// maskTables are the pruning tables for one mask and set of moves.
+type maskTables struct {
+	chunks []*maskChunk
+	// eo and co are nil unless the mask orients some edges or corners
+	eo, co []byte
+}
+
+// maskState is the cube as the masked search sees it.
+type maskState struct {
+	chunks [maskChunkCount]int
+	eo, co int
+}
+
+// maskKey is a mask along with the metric and moves it's searched with.
+type maskKey struct {
+	mask   Mask
+	suffix string
+}
+
+// maskCache holds the tables for every mask used so far, by maskKey,
+// and every chunk table, by its name. Masks which share a chunk of
+// pieces share its table. Everything is kept for as long as the program
+// runs, so a program which takes masks from its users should only accept
+// a few, see StepMask.
+var maskCache = struct {
+	sync.Mutex
+	entries map[any]*maskEntry
+}{entries: make(map[any]*maskEntry)}
+
+// maskEntry is one table in maskCache. It's built once, outside the
+// cache's lock, so a solve with tables that are ready never waits on one
+// whose tables are being built.
+type maskEntry struct {
+	once  sync.Once
+	value any
+}
+
+// cachedMaskTable returns the table in maskCache for key, calling build
+// to make it the first time it's asked for.
+func cachedMaskTable(key any, build func() any) any {
+	maskCache.Lock()
+	entry, ok := maskCache.entries[key]
+	if !ok {
+		entry = &maskEntry{}
+		maskCache.entries[key] = entry
+	}
+	maskCache.Unlock()
+	entry.once.Do(func() {
+		entry.value = build()
+	})
+	return entry.value
+}
+
+// tablesForMask returns the tables for a mask, building them if they
+// haven't been already.
+func tablesForMask(mask Mask, t *metricTables) *maskTables {
+	tablesOnce.Do(initTables)
+	return cachedMaskTable(maskKey{mask, t.suffix}, func() any {
+		return newMaskTables(mask, t)
+	}).(*maskTables)
+}
+
+func newMaskTables(mask Mask, t *metricTables) *maskTables {
+	var pieces []maskPiece
+	for i, pm := range mask.Edges {
+		if pm.Index {
+			pieces = append(pieces, maskPiece{kind: 0, index: i, goals: pieceGoals(i, 2, pm.Orientation)})
+		}
+	}
+	for i, pm := range mask.Corners {
+		if pm.Index {
+			pieces = append(pieces, maskPiece{kind: 1, index: i, goals: pieceGoals(i, 3, pm.Orientation)})
+		}
+	}
+
+	mt := &maskTables{}
+	for len(pieces) > 0 {
+		n := min(len(pieces), maskChunkSize)
+		mt.chunks = append(mt.chunks, chunkTable(pieces[:n], t))
+		pieces = pieces[n:]
+	}
+
+	var edgesOriented, cornersOriented []int
+	for i, pm := range mask.Edges {
+		if pm.Orientation {
+			edgesOriented = append(edgesOriented, i)
+		}
+	}
+	for i, pm := range mask.Corners {
+		if pm.Orientation {
+			cornersOriented = append(cornersOriented, i)
+		}
+	}
+	if len(edgesOriented) > 0 {
+		mt.eo = make([]byte, 2048)
+		var goals []int
+		for i := range mt.eo {
+			c := fromEOCoordinate(i)
+			if oriented(c.Edges[:], edgesOriented) {
+				goals = append(goals, i)
+			}
+		}
+		t.buildDistanceTable(mt.eo, t.phase1Moves, goals, func(i, m int) int {
+			return eoLookup[i][m]
+		})
+	}
+	if len(cornersOriented) > 0 {
+		mt.co = make([]byte, 2187)
+		var goals []int
+		for i := range mt.co {
+			c := fromCOCoordinate(i)
+			if oriented(c.Corners[:], cornersOriented) {
+				goals = append(goals, i)
+			}
+		}
+		t.buildDistanceTable(mt.co, t.phase1Moves, goals, func(i, m int) int {
+			return coLookup[i][m]
+		})
+	}
+	return mt
+}
+
+// chunkTable returns the chunk for some pieces of a mask, with its table
+// built, or loaded if it's been saved.
+func chunkTable(pieces []maskPiece, t *metricTables) *maskChunk {
+	name := "mask"
+	for _, p := range pieces {
+		name += "-" + p.name()
+	}
+	name += t.suffix
+	return cachedMaskTable(name, func() any {
+		chunk := &maskChunk{pieces: pieces}
+		size := 1
+		for range pieces {
+			size *= 24
+		}
+		chunk.table = make([]byte, size)
+		loadTable(name, chunk.table, func() {
+			t.buildDistanceTable(chunk.table, t.phase1Moves, chunk.goals(), chunk.move)
+		})
+		return chunk
+	}).(*maskChunk)
+}
+
+// pieceGoals are the states a piece is solved in: at home, and oriented
+// if it needs to be.
+func pieceGoals(index, twists int, orient bool) []int {
+	if orient {
+		return []int{index * twists}
+	}
+	result := make([]int, twists)
+	for o := range result {
+		result[o] = index*twists + o
+	}
+	return result
+}
+
+// oriented reports whether the pieces in every one of positions are
+// oriented.
+func oriented(pieces []Piece, positions []int) bool {
+	for _, p := range positions {
+		if pieces[p].Orientation != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// goals are the states of the chunk where every piece is solved.
+func (c *maskChunk) goals() []int {
+	result := []int{0}
+	scale := 1
+	for _, p := range c.pieces {
+		var next []int
+		for _, r := range result {
+			for _, g := range p.goals {
+				next = append(next, r+g*scale)
+			}
+		}
+		result = next
+		scale *= 24
+	}
+	return result
+}
+
+// move returns the chunk state that move m takes state to.
+func (c *maskChunk) move(state, m int) int {
+	result := 0
+	scale := 1
+	for _, p := range c.pieces {
+		result += int(pieceMoves[p.kind][state%24][m]) * scale
+		state /= 24
+		scale *= 24
+	}
+	return result
+}
+
+func (mt *maskTables) state(c Cube) maskState {
+	var result maskState
+	for i, chunk := range mt.chunks {
+		scale := 1
+		for _, p := range chunk.pieces {
+			var s int
+			if p.kind == 0 {
+				for pos, e := range c.Edges {
+					if e.Index == p.index {
+						s = pos*2 + e.Orientation
+					}
+				}
+			} else {
+				for pos, corner := range c.Corners {
+					if corner.Index == p.index {
+						s = pos*3 + corner.Orientation
+					}
+				}
+			}
+			result.chunks[i] += s * scale
+			scale *= 24
+		}
+	}
+	result.eo = toEOCoordinate(c)
+	result.co = toCOCoordinate(c)
+	return result
+}
+
+func (mt *maskTables) move(s maskState, m int) maskState {
+	for i := range mt.chunks {
+		s.chunks[i] = mt.chunks[i].move(s.chunks[i], m)
+	}
+	s.eo = eoLookup[s.eo][m]
+	s.co = coLookup[s.co][m]
+	return s
+}
+
+// bound is the largest of the tables, but it stops looking as soon as
+// it reaches bound. It's 0xff if the moves can never solve the mask.
+func (mt *maskTables) bound(s maskState, bound int) int {
+	result := 0
+	if mt.eo != nil {
+		result = int(mt.eo[s.eo])
+	}
+	if mt.co != nil {
+		result = max(result, int(mt.co[s.co]))
+	}
+	for i := 0; i < len(mt.chunks) && result < bound; i++ {
+		result = max(result, int(mt.chunks[i].table[s.chunks[i]]))
+	}
+	return result
+}
+
+// solveMasked runs IDA* on the masked cube, so the first solution found
+// is the shortest.
+func (s *solver) solveMasked() {
+	state := s.masked.state(s.scrambledCube)
+	for depth := s.masked.bound(state, 0xff); ; depth++ {
+		if s.searchMasked(state, depth) {
+			return
+		}
+	}
+}
+
+func (s *solver) searchMasked(state maskState, depth int) bool {
+	s.nodes++
+	if s.cancelled() {
+		return true
+	}
+	if depth == 0 {
+		s.recordSolution(append([]int(nil), s.path...), len(s.path))
+		return true
+	}
+	for _, m := range s.tables.phase1Moves {
+		if s.tables.redundant(s.path, m) || s.tables.outOfOrder(s.path, m) {
+			continue
+		}
+
+		next := s.masked.move(state, m)
+		if s.masked.bound(next, depth) >= depth {
+			continue
+		}
+
+		s.path = append(s.path, m)
+		if s.searchMasked(next, depth-1) {
+			return true
+		}
+		s.path = s.path[0 : len(s.path)-1]
+	}
+	return false
+}
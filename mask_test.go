@@ -0,0 +1,119 @@
+package cube
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestParseMask(t *testing.T) {
+	m, err := ParseMask("DF rdf", "UB DF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want Mask
+	want.Edges[4] = PieceMask{Index: true, Orientation: true}
+	want.Corners[5] = PieceMask{Index: true, Orientation: true}
+	want.Edges[0] = PieceMask{Orientation: true}
+	if m != want {
+		t.Errorf("got %+v, want %+v", m, want)
+	}
+
+	for _, name := range []string{"UD", "UFB", "X", "UFRB"} {
+		if _, err := ParseMask(name, ""); err == nil {
+			t.Errorf("%q: got no error", name)
+		}
+		if _, err := ParseMask("", name); err == nil {
+			t.Errorf("orienting %q: got no error", name)
+		}
+	}
+}
+
+func TestMaskSolved(t *testing.T) {
+	tests := []struct {
+		name  string
+		mask  Mask
+		moves []Move
+		want  bool
+	}{
+		{"solved", ollMask, nil, true},
+		{"cross after U", crossMask, []Move{MoveU}, true},
+		{"cross after R", crossMask, []Move{MoveR}, false},
+		{"fr pair after L", pairMasks["fr"], []Move{MoveL}, false},
+		{"fr pair after U", pairMasks["fr"], []Move{MoveU}, true},
+		// U moves the last layer's pieces, but they stay oriented
+		{"oll after U", ollMask, []Move{MoveU}, true},
+		{"oll after sune", ollMask, []Move{MoveR, MoveU, MoveR3, MoveU, MoveR, MoveU2, MoveR3}, false},
+		{"f2l after sune", f2lMask, []Move{MoveR, MoveU, MoveR3, MoveU, MoveR, MoveU2, MoveR3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mask.Solved(cubeSolved.ApplySequence(tt.moves)); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepMask(t *testing.T) {
+	for _, step := range []string{"cross", "fl", "FR", "br", "bl", "oll"} {
+		if _, err := StepMask(step); err != nil {
+			t.Errorf("%s: %v", step, err)
+		}
+	}
+	for _, step := range []string{"", "f2l", "pll", "xcross"} {
+		if _, err := StepMask(step); err == nil {
+			t.Errorf("%q: got no error", step)
+		}
+	}
+
+	sune := cubeSolved.ApplySequence([]Move{MoveR, MoveU, MoveR3, MoveU, MoveR, MoveU2, MoveR3})
+	if !StepReady("oll", sune) {
+		t.Error("oll isn't ready with F2L solved")
+	}
+	if StepReady("oll", cubeSolved.Apply(MoveR)) {
+		t.Error("oll is ready with F2L unsolved")
+	}
+	if !StepReady("cross", RandomCube(rand.New(rand.NewSource(1)))) {
+		t.Error("cross isn't ready on a random cube")
+	}
+}
+
+func TestSolveMasked(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sune := cubeSolved.ApplySequence([]Move{MoveR, MoveU, MoveR3, MoveU, MoveR, MoveU2, MoveR3})
+	tests := []struct {
+		name    string
+		step    string
+		metrics []Metric
+		cube    Cube
+		// most is the most moves the step can take in the first
+		// metric, to check the solution is short
+		most int
+	}{
+		{"cross", "cross", []Metric{HTM, QTM, STM}, RandomCube(rng), 8},
+		{"pair", "fr", []Metric{HTM}, RandomCube(rng), 11},
+		{"oll after sune", "oll", []Metric{HTM}, sune, 7},
+		{"already solved", "cross", []Metric{HTM}, cubeSolved.Apply(MoveU), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask, err := StepMask(tt.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, metric := range tt.metrics {
+				solution, err := SolveWithOptions(context.Background(), tt.cube, SolveOptions{Metric: metric, Mask: &mask})
+				if err != nil {
+					t.Fatalf("%v: %v", metric, err)
+				}
+				if !mask.Solved(tt.cube.ApplySequence(solution.Moves)) {
+					t.Errorf("%v: %v doesn't solve the step", metric, solution)
+				}
+				if i == 0 && solution.Length(metric) > tt.most {
+					t.Errorf("%v: %v is longer than %d moves", metric, solution, tt.most)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package cube
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Metric is the way the length of a solution is measured, and so what
+// the search tries to keep short.
+type Metric int
+
+const (
+	// HTM is the half turn metric, where every face turn is one move.
+	HTM Metric = iota
+	// QTM is the quarter turn metric, where a half turn is two moves.
+	QTM
+	// STM is the slice turn metric, where turning a middle layer, M, E
+	// or S, is one move like a face turn. Solutions still turn faces,
+	// but String writes them in SiGN with the slice turns put back in,
+	// see FormatSiGN.
+	STM
+	metricCount
+)
+
+// String returns the metric's usual abbreviation in lower case.
+func (m Metric) String() string {
+	switch m {
+	case HTM:
+		return "htm"
+	case QTM:
+		return "qtm"
+	case STM:
+		return "stm"
+	}
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+// metricTables are the moves the two phase search uses for one metric,
+// and the pruning tables built with them. The tables hold the cost to
+// finish each phase in the metric, so they're built separately for
+// every metric, the first time it's used.
+type metricTables struct {
+	// suffix is added to the names of saved tables
+	suffix string
+	// phase1Moves and phase2Moves are the moves searched in each phase
+	phase1Moves []int
+	phase2Moves []int
+	// inPhase2 marks the moves in phase2Moves
+	inPhase2 [searchMoveCount]bool
+	// cost is the length of each move in the metric
+	cost [searchMoveCount]int
+	// movesPerHTM is the most moves of the metric that one HTM move can
+	// stand in for, which turns an HTM distance into a lower bound
+	movesPerHTM int
+	// repeatQuarterTurns allows the same quarter turn twice in a row. In
+	// QTM phase 1 only searches quarter turns, which keeps neighboring
+	// coordinates within one move of each other as phase1MinMoves
+	// needs, so half turns are made out of two quarter turns instead.
+	repeatQuarterTurns bool
+	// orientations are the orientations the two phase search looks at
+	// the cube from, see orientationCount
+	orientations []int
+	// group is the cubes a restricted set of moves can reach, or nil if
+	// every move is allowed, see restrictedTables
+	group *permGroup
+	// twoPhase is set when the two phase search works with these moves
+	twoPhase bool
+
+	once                       sync.Once
+	phase1MinMoves             []byte
+	phase1EOSliceMinMoves      []byte
+	phase1COSliceMinMoves      []byte
+	phase2CornerESliceMinMoves []byte
+	phase2AllEdgesMinMoves     []byte
+}
+
+var metrics = [metricCount]*metricTables{
+	HTM: newMetricTables(HTM),
+	QTM: newMetricTables(QTM),
+	STM: newMetricTables(STM),
+}
+
+func newMetricTables(metric Metric) *metricTables {
+	t := &metricTables{movesPerHTM: 1, twoPhase: true}
+	for o := 0; o < orientationCount; o++ {
+		t.orientations = append(t.orientations, o)
+	}
+	count := moveCount
+	if metric == STM {
+		count = searchMoveCount
+		// a slice turn is two HTM moves
+		t.movesPerHTM = 2
+	}
+	for m := 0; m < count; m++ {
+		t.cost[m] = 1
+		if metric == QTM && m%3 == 1 {
+			t.cost[m] = 2
+		}
+		if metric != QTM || m%3 != 1 {
+			t.phase1Moves = append(t.phase1Moves, m)
+		}
+		// quarter turns of the side faces and of M and S break phase 2
+		if m%3 == 1 || m < moveL || m > moveB3 && m < moveM || m >= moveE && m <= moveE3 {
+			t.phase2Moves = append(t.phase2Moves, m)
+			t.inPhase2[m] = true
+		}
+	}
+	if metric != HTM {
+		t.suffix = "-" + metric.String()
+		t.repeatQuarterTurns = metric == QTM
+	}
+	return t
+}
+
+// PrepareMetric is Prepare for solving in a metric other than HTM.
+func PrepareMetric(metric Metric) {
+	metrics[metric].prepare()
+}
+
+// prepare builds, or loads, the tables the two phase search needs.
+func (t *metricTables) prepare() {
+	tablesOnce.Do(initTables)
+	if t.group == nil {
+		phase1Once.Do(initPhase1)
+	}
+	t.once.Do(t.init)
+}
+
+// moveAxis is the axis a face or slice turn turns around, 0-2 for the
+// UD, LR and FB axes.
+func moveAxis(m int) int {
+	if m >= moveCount {
+		// M, E and S turn around the LR, UD and FB axes
+		return [3]int{1, 0, 2}[(m-moveCount)/3]
+	}
+	face := m / 3
+	return min(face, oppositeFace[face]) % 3
+}
+
+// redundant reports whether move m can be skipped after the moves in
+// path, because the search will try the same turns another way: turning
+// the same face twice in a row is the same as turning it once, except
+// when two quarter turns are standing in for a half turn.
+func (t *metricTables) redundant(path []int, m int) bool {
+	return t.redundantAfter(path, m, t.repeatQuarterTurns)
+}
+
+// redundantPhase2 is redundant for phase 2, which always has half turns.
+func (t *metricTables) redundantPhase2(path []int, m int) bool {
+	return t.redundantAfter(path, m, false)
+}
+
+func (t *metricTables) redundantAfter(path []int, m int, repeatQuarterTurns bool) bool {
+	if len(path) == 0 {
+		return false
+	}
+	last := path[len(path)-1]
+	if moveAxis(m) != moveAxis(last) {
+		return false
+	}
+	if m >= moveCount || last >= moveCount {
+		// a slice turn and anything else on its axis is two face turns
+		return true
+	}
+	if m/3 != last/3 {
+		if t.movesPerHTM == 1 {
+			return false
+		}
+		// in STM, opposite faces turned the same way as a slice are the
+		// slice turn, and any more turns on the same axis can be made
+		// with two of them
+		return isSliceTurn(Move(last), Move(m)) || len(path) > 1 && moveAxis(path[len(path)-2]) == moveAxis(m)
+	}
+	if !repeatQuarterTurns || m != last {
+		return true
+	}
+	// a third quarter turn is the same as one turn the other way
+	return len(path) > 1 && path[len(path)-2]/3 == m/3
+}
+
+// outOfOrder reports whether move m can be skipped after the moves in
+// path because it turns the face opposite the last move. The two turns
+// commute, so the IDA* searches only try them with the lower face first.
+func (t *metricTables) outOfOrder(path []int, m int) bool {
+	if len(path) == 0 || m >= moveCount || path[len(path)-1] >= moveCount {
+		return false
+	}
+	lastMoveFace := path[len(path)-1] / 3
+	mFace := m / 3
+	return mFace == oppositeFace[lastMoveFace] && mFace < lastMoveFace
+}
+
+// endsPhase1 reports whether a phase 1 solution could end with the moves
+// in path. It must end with a move that isn't in phase 2, otherwise the
+// cube was already in the phase 2 subgroup a move earlier, and that
+// includes a half turn made out of two quarter turns.
+func (t *metricTables) endsPhase1(path []int) bool {
+	n := len(path)
+	if n == 0 {
+		return true
+	}
+	m := path[n-1]
+	return !t.inPhase2[m] && (n < 2 || path[n-2] != m)
+}
+
+// pathCost is the length of a sequence of moves in the metric.
+func (t *metricTables) pathCost(path []int) int {
+	result := 0
+	for _, m := range path {
+		result += t.cost[m]
+	}
+	return result
+}
+
+// expandSliceTurns replaces each slice turn in path with the face turns
+// it's made of, and adjusts the length of phase 1 to match.
+func expandSliceTurns(path []int, phase1Length int) ([]int, int) {
+	result := make([]int, 0, len(path))
+	newPhase1Length := phase1Length
+	for i, m := range path {
+		if m < moveCount {
+			result = append(result, m)
+			continue
+		}
+		result = append(result, sliceTurns[m-moveCount][:]...)
+		if i < phase1Length {
+			newPhase1Length++
+		}
+	}
+	return result, newPhase1Length
+}
+
+// mergeQuarterTurns replaces each pair of the same quarter turn with a
+// half turn, and adjusts the length of phase 1 to match.
+func mergeQuarterTurns(path []int, phase1Length int) ([]int, int) {
+	result := make([]int, 0, len(path))
+	newPhase1Length := phase1Length
+	for i, m := range path {
+		if n := len(result); n > 0 && result[n-1] == m && m%3 != 1 {
+			result[n-1] = m/3*3 + 1
+			if i < phase1Length {
+				newPhase1Length--
+			}
+			continue
+		}
+		result = append(result, m)
+	}
+	return result, newPhase1Length
+}
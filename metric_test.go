@@ -0,0 +1,189 @@
+package cube
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// ponsAsinorum is the checkerboard pattern, R2 L2 U2 D2 F2 B2
+var ponsAsinorum = cubeSolved.ApplySequence([]Move{MoveR2, MoveL2, MoveU2, MoveD2, MoveF2, MoveB2})
+
+// searchPath returns a random path the two phase search could take in
+// t, with phase1Length moves of phase 1 followed by phase2Length of
+// phase 2, skipping moves just as the search does.
+func searchPath(rng *rand.Rand, t *metricTables, phase1Length, phase2Length int) []int {
+	var path []int
+	for len(path) < phase1Length {
+		m := t.phase1Moves[rng.Intn(len(t.phase1Moves))]
+		if !t.redundant(path, m) {
+			path = append(path, m)
+		}
+	}
+	for len(path) < phase1Length+phase2Length {
+		m := t.phase2Moves[rng.Intn(len(t.phase2Moves))]
+		previous := path[phase1Length:]
+		if len(previous) == 0 {
+			previous = path
+		}
+		if !t.redundantPhase2(previous, m) {
+			path = append(path, m)
+		}
+	}
+	return path
+}
+
+func TestMetricLength(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, metric := range []Metric{HTM, QTM, STM} {
+		tables := metrics[metric]
+		for i := 0; i < 1000; i++ {
+			phase1Length := rng.Intn(13)
+			path := searchPath(rng, tables, phase1Length, rng.Intn(11))
+			// the same steps as recordSolution
+			expanded, expandedPhase1 := expandSliceTurns(path, phase1Length)
+			expanded, expandedPhase1 = mergeQuarterTurns(expanded, expandedPhase1)
+			solution := newSolution(expanded, expandedPhase1)
+			if got, want := solution.Length(metric), tables.pathCost(path); got != want {
+				t.Fatalf("%v: %v is %d moves, but the search counted %d", metric, solution, got, want)
+			}
+
+			// the solution has to turn the cube the same way as the
+			// path, in both phases
+			apply := func(path []int) Cube {
+				c := cubeSolved
+				for _, m := range path {
+					c = transform(c, searchMoves[m])
+				}
+				return c
+			}
+			if c := cubeSolved.ApplySequence(solution.Phase1()); c != apply(path[:phase1Length]) {
+				t.Fatalf("%v: %v doesn't have the search's phase 1, %v", metric, solution, path[:phase1Length])
+			}
+			if c := cubeSolved.ApplySequence(solution.Moves); c != apply(path) {
+				t.Fatalf("%v: %v isn't the same as the search's path", metric, solution)
+			}
+		}
+	}
+}
+
+func TestMergeQuarterTurns(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         []int
+		phase1Length int
+		want         []int
+		wantPhase1   int
+	}{
+		{"none", []int{moveR, moveU}, 1, []int{moveR, moveU}, 1},
+		{"pair", []int{moveR, moveR, moveU}, 2, []int{moveR2, moveU}, 1},
+		{"inverse pair", []int{moveU, moveR3, moveR3}, 3, []int{moveU, moveR2}, 2},
+		{"in phase 2", []int{moveR, moveU, moveU}, 1, []int{moveR, moveU2}, 1},
+		{"different turns", []int{moveR, moveR3}, 2, []int{moveR, moveR3}, 2},
+		{"half turns", []int{moveR2, moveU2}, 1, []int{moveR2, moveU2}, 1},
+		{"two in phase 1", []int{moveR, moveR, moveF, moveF, moveU}, 4, []int{moveR2, moveF2, moveU}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, phase1 := mergeQuarterTurns(tt.path, tt.phase1Length)
+			if !equalPaths(got, tt.want) || phase1 != tt.wantPhase1 {
+				t.Errorf("got %v with phase 1 %d, want %v with phase 1 %d", got, phase1, tt.want, tt.wantPhase1)
+			}
+		})
+	}
+}
+
+func TestExpandSliceTurns(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         []int
+		phase1Length int
+		want         []int
+		wantPhase1   int
+	}{
+		{"none", []int{moveR, moveU}, 1, []int{moveR, moveU}, 1},
+		{"M", []int{moveM, moveU}, 1, []int{moveR, moveL3, moveU}, 2},
+		{"E2 in phase 2", []int{moveF, moveE2}, 1, []int{moveF, moveU2, moveD2}, 1},
+		{"S' in both", []int{moveS3, moveU, moveS3}, 2, []int{moveF, moveB3, moveU, moveF, moveB3}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, phase1 := expandSliceTurns(tt.path, tt.phase1Length)
+			if !equalPaths(got, tt.want) || phase1 != tt.wantPhase1 {
+				t.Errorf("got %v with phase 1 %d, want %v with phase 1 %d", got, phase1, tt.want, tt.wantPhase1)
+			}
+		})
+	}
+}
+
+func equalPaths(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSolveMetrics(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, metric := range []Metric{HTM, QTM, STM} {
+		for i := 0; i < 5; i++ {
+			c := RandomCube(rng)
+			last := 999
+			solution, err := SolveWithOptions(context.Background(), c, SolveOptions{
+				Metric: metric,
+				OnSolution: func(s Solution) {
+					// each solution is only reported if the search
+					// found it shorter than the last
+					if s.Length(metric) >= last {
+						t.Errorf("%v: %v isn't shorter than %d", metric, s, last)
+					}
+					last = s.Length(metric)
+				},
+			})
+			if err != nil {
+				t.Fatalf("%v: %v", metric, err)
+			}
+			if solution.Metric != metric {
+				t.Errorf("solution's metric is %v, want %v", solution.Metric, metric)
+			}
+			if !c.ApplySequence(solution.Moves).IsSolved() {
+				t.Errorf("%v: %v doesn't solve the cube", metric, solution)
+			}
+		}
+	}
+}
+
+func TestPonsAsinorum(t *testing.T) {
+	tests := []struct {
+		metric Metric
+		length int
+	}{
+		{HTM, 6},
+		{QTM, 12},
+		{STM, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			solution, err := SolveWithOptions(context.Background(), ponsAsinorum, SolveOptions{
+				Metric:    tt.metric,
+				MaxLength: tt.length,
+				Timeout:   10 * time.Second,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := solution.Length(tt.metric); got != tt.length {
+				t.Errorf("%v is %d%v, want %d", solution, got, tt.metric, tt.length)
+			}
+			if !ponsAsinorum.ApplySequence(solution.Moves).IsSolved() {
+				t.Errorf("%v doesn't solve the cube", solution)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package cube
 
-var cubeSolved = cube{
-	Edges: [edgeCount]piece{
+var cubeSolved = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeUB, Orientation: 0},
 		{Index: edgeUR, Orientation: 0},
 		{Index: edgeUF, Orientation: 0},
@@ -15,7 +15,7 @@ var cubeSolved = cube{
 		{Index: edgeBR, Orientation: 0},
 		{Index: edgeBL, Orientation: 0},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerUBL, Orientation: 0},
 		{Index: cornerURB, Orientation: 0},
 		{Index: cornerUFR, Orientation: 0},
@@ -27,8 +27,8 @@ var cubeSolved = cube{
 	},
 }
 
-var cubeU = cube{
-	Edges: [edgeCount]piece{
+var cubeU = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeUL, Orientation: 0},
 		{Index: edgeUB, Orientation: 0},
 		{Index: edgeUR, Orientation: 0},
@@ -42,7 +42,7 @@ var cubeU = cube{
 		{Index: edgeBR, Orientation: 0},
 		{Index: edgeBL, Orientation: 0},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerULF, Orientation: 0},
 		{Index: cornerUBL, Orientation: 0},
 		{Index: cornerURB, Orientation: 0},
@@ -54,8 +54,8 @@ var cubeU = cube{
 	},
 }
 
-var cubeL = cube{
-	Edges: [edgeCount]piece{
+var cubeL = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeUB, Orientation: 0},
 		{Index: edgeUR, Orientation: 0},
 		{Index: edgeUF, Orientation: 0},
@@ -69,7 +69,7 @@ var cubeL = cube{
 		{Index: edgeBR, Orientation: 0},
 		{Index: edgeDL, Orientation: 0},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerDLB, Orientation: 2},
 		{Index: cornerURB, Orientation: 0},
 		{Index: cornerUFR, Orientation: 0},
@@ -81,8 +81,8 @@ var cubeL = cube{
 	},
 }
 
-var cubeF = cube{
-	Edges: [edgeCount]piece{
+var cubeF = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeUB, Orientation: 0},
 		{Index: edgeUR, Orientation: 0},
 		{Index: edgeFL, Orientation: 1},
@@ -96,7 +96,7 @@ var cubeF = cube{
 		{Index: edgeBR, Orientation: 0},
 		{Index: edgeBL, Orientation: 0},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerUBL, Orientation: 0},
 		{Index: cornerURB, Orientation: 0},
 		{Index: cornerULF, Orientation: 1},
@@ -108,8 +108,8 @@ var cubeF = cube{
 	},
 }
 
-var cubeR = cube{
-	Edges: [edgeCount]piece{
+var cubeR = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeUB, Orientation: 0},
 		{Index: edgeFR, Orientation: 0},
 		{Index: edgeUF, Orientation: 0},
@@ -123,7 +123,7 @@ var cubeR = cube{
 		{Index: edgeUR, Orientation: 0},
 		{Index: edgeBL, Orientation: 0},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerUBL, Orientation: 0},
 		{Index: cornerUFR, Orientation: 1},
 		{Index: cornerDRF, Orientation: 2},
@@ -135,8 +135,8 @@ var cubeR = cube{
 	},
 }
 
-var cubeB = cube{
-	Edges: [edgeCount]piece{
+var cubeB = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeBR, Orientation: 1},
 		{Index: edgeUR, Orientation: 0},
 		{Index: edgeUF, Orientation: 0},
@@ -150,7 +150,7 @@ var cubeB = cube{
 		{Index: edgeDB, Orientation: 1},
 		{Index: edgeUB, Orientation: 1},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerURB, Orientation: 1},
 		{Index: cornerDBR, Orientation: 2},
 		{Index: cornerUFR, Orientation: 0},
@@ -162,8 +162,8 @@ var cubeB = cube{
 	},
 }
 
-var cubeD = cube{
-	Edges: [edgeCount]piece{
+var cubeD = Cube{
+	Edges: [edgeCount]Piece{
 		{Index: edgeUB, Orientation: 0},
 		{Index: edgeUR, Orientation: 0},
 		{Index: edgeUF, Orientation: 0},
@@ -177,7 +177,7 @@ var cubeD = cube{
 		{Index: edgeBR, Orientation: 0},
 		{Index: edgeBL, Orientation: 0},
 	},
-	Corners: [cornerCount]piece{
+	Corners: [cornerCount]Piece{
 		{Index: cornerUBL, Orientation: 0},
 		{Index: cornerURB, Orientation: 0},
 		{Index: cornerUFR, Orientation: 0},
@@ -189,7 +189,69 @@ var cubeD = cube{
 	},
 }
 
-var moves = [moveCount]cube{
+// cubeX and cubeY are whole cube rotations rather than moves: x turns
+// the entire cube the same way as R, and y the same way as U. They are
+// used to look at a cube from a different orientation, see conjugate.
+var cubeX = Cube{
+	Edges: [edgeCount]Piece{
+		{Index: edgeUF, Orientation: 1},
+		{Index: edgeFR, Orientation: 0},
+		{Index: edgeDF, Orientation: 1},
+		{Index: edgeFL, Orientation: 0},
+		{Index: edgeDB, Orientation: 1},
+		{Index: edgeBR, Orientation: 0},
+		{Index: edgeUB, Orientation: 1},
+		{Index: edgeBL, Orientation: 0},
+		{Index: edgeDL, Orientation: 0},
+		{Index: edgeDR, Orientation: 0},
+		{Index: edgeUR, Orientation: 0},
+		{Index: edgeUL, Orientation: 0},
+	},
+	Corners: [cornerCount]Piece{
+		{Index: cornerULF, Orientation: 2},
+		{Index: cornerUFR, Orientation: 1},
+		{Index: cornerDRF, Orientation: 2},
+		{Index: cornerDFL, Orientation: 1},
+		{Index: cornerDLB, Orientation: 2},
+		{Index: cornerDBR, Orientation: 1},
+		{Index: cornerURB, Orientation: 2},
+		{Index: cornerUBL, Orientation: 1},
+	},
+}
+
+var cubeY = Cube{
+	Edges: [edgeCount]Piece{
+		{Index: edgeUL, Orientation: 0},
+		{Index: edgeUB, Orientation: 0},
+		{Index: edgeUR, Orientation: 0},
+		{Index: edgeUF, Orientation: 0},
+		{Index: edgeDR, Orientation: 0},
+		{Index: edgeDB, Orientation: 0},
+		{Index: edgeDL, Orientation: 0},
+		{Index: edgeDF, Orientation: 0},
+		{Index: edgeFR, Orientation: 1},
+		{Index: edgeBR, Orientation: 1},
+		{Index: edgeBL, Orientation: 1},
+		{Index: edgeFL, Orientation: 1},
+	},
+	Corners: [cornerCount]Piece{
+		{Index: cornerULF, Orientation: 0},
+		{Index: cornerUBL, Orientation: 0},
+		{Index: cornerURB, Orientation: 0},
+		{Index: cornerUFR, Orientation: 0},
+		{Index: cornerDRF, Orientation: 0},
+		{Index: cornerDBR, Orientation: 0},
+		{Index: cornerDLB, Orientation: 0},
+		{Index: cornerDFL, Orientation: 0},
+	},
+}
+
+// cubeURF3 rotates the cube 120 degrees around the axis through the UFR
+// and DLB corners, which cycles the U, R and F faces (and so the UD, RL
+// and FB axes).
+var cubeURF3 = transform(cubeX, cubeY)
+
+var moves = [moveCount]Cube{
 	cubeU,
 	transform(cubeU, cubeU),
 	transform(cubeU, transform(cubeU, cubeU)),
@@ -209,3 +271,29 @@ var moves = [moveCount]cube{
 	transform(cubeD, cubeD),
 	transform(cubeD, transform(cubeD, cubeD)),
 }
+
+// sliceTurns are the face turns each slice turn is made of. The cubie
+// model keeps the centers still, so a slice turn is the two faces either
+// side of it turned the other way, see parseMoves.
+var sliceTurns = [searchMoveCount - moveCount][2]int{
+	{moveR, moveL3},
+	{moveR2, moveL2},
+	{moveR3, moveL},
+	{moveU, moveD3},
+	{moveU2, moveD2},
+	{moveU3, moveD},
+	{moveF3, moveB},
+	{moveF2, moveB2},
+	{moveF, moveB3},
+}
+
+// searchMoves are the face turns followed by the slice turns, which is
+// every move the move tables cover.
+var searchMoves = func() [searchMoveCount]Cube {
+	var result [searchMoveCount]Cube
+	copy(result[:], moves[:])
+	for i, turns := range sliceTurns {
+		result[moveCount+i] = transform(moves[turns[0]], moves[turns[1]])
+	}
+	return result
+}()
@@ -0,0 +1,350 @@
+package cube
+
+import "sync"
+
+// The optimal solver is an IDA* search over all 18 face turns, the same
+// approach Korf used to find the first optimal solutions of random cubes.
+// Unlike the two phase search there is no subgroup to aim for, so the
+// search relies entirely on much larger pruning tables to cut down the
+// tree:
+//
+//   - the exact distance to solve all 8 corners, 8! * 3^7 = 88,179,840
+//     entries
+//   - the exact distance to solve 6 of the 12 edges, 12!/6! * 2^6 =
+//     42,577,920 entries
+//
+// Both tables hold distances in the full 18 move group so they are both
+// lower bounds on the solution length. Rather than building separate
+// tables for the other edges, we look the cube up a second and third time
+// after rotating it 120 degrees around the UFR-DLB diagonal (cubeURF3).
+// Rotating the cube doesn't change how far it is from solved, but it moves
+// a different set of edges into the 6 tracked slots, so one edge table
+// covers all 12 edges and the corner table gets three looks at the corners.
+//
+// This puts the cube's symmetry to work the other way round from the
+// phase 1 tables, which store one entry per set of symmetric cubes (see
+// symmetry.go). Reducing the edge table like that only works for the
+// symmetries which keep the 6 tracked edges among themselves, and of the
+// 16 only the identity, y2 and the LR reflection, alone or together, do.
+// That would save at most three quarters of the edge table's 21MB, at
+// the cost of a table of symmetry classes and a class lookup on every
+// probe. Spending the symmetry on extra lookups instead costs no memory
+// and makes the bound tighter, since the largest of the six is used. The
+// corner table is the one that would shrink the most, by the 16
+// symmetries from 44MB to around 3MB, but it isn't reduced yet.
+//
+// In QTM the search only turns quarter turns, with a half turn made out
+// of two of them. The tables still hold HTM distances, which are never
+// more than the QTM distance, so they're a lower bound there too. In STM
+// the search adds the slice turns, and since each of those is two face
+// turns the HTM distance is halved to get a lower bound.
+//
+// Together with their move tables they take around 125MB and 15 seconds
+// or so to build, so they are only built the first time an optimal solve
+// is requested, and the pruning tables are saved to the table directory
+// (see tables.go) to be loaded next time.
+
+const (
+	cornerStateCount = 40320 * 2187
+	edge6PermCount   = 12 * 11 * 10 * 9 * 8 * 7
+	edge6StateCount  = edge6PermCount * 64
+
+	// edge6Tracked is the number of edges covered by the edge table
+	edge6Tracked = 6
+
+	// unvisited marks entries of a pruning table which haven't been
+	// reached yet while the table is being built
+	unvisited = 0xf
+)
+
+var optimalOnce sync.Once
+
+// edge6Slots gives the slot of each tracked edge in the edge coordinate,
+// or -1 for edges that aren't tracked. UB UR UF UL DF DB are chosen so
+// that between the three axes every edge is looked at at least once.
+var edge6Slots = [edgeCount]int{0, 1, 2, 3, 4, -1, 5, -1, -1, -1, -1, -1}
+
+// optimalCorners and optimalEdges hold the min moves for every corner
+// state and every state of the 6 tracked edges. Distances never exceed
+// 15 so two entries are packed into each byte.
+var optimalCorners []byte
+var optimalEdges []byte
+
+// the edge coordinate is split into the permutation of the 6 tracked
+// edges and their orientation. Only the permutation needs a lookup table,
+// the orientation changes by xoring in the flips that a move applies at
+// the tracked edges' positions.
+var edge6PermLookup [][moveCount]int32
+var edge6FlipLookup [][moveCount]uint8
+
+func initOptimal() {
+	initEdge6()
+	optimalCorners = make([]byte, (cornerStateCount+1)/2)
+	loadTable("optimal-corners", optimalCorners, func() {
+		buildPruneTable(optimalCorners, cornerStateCount, 0, func(i, m int) int {
+			return cpLookup[i/2187][m]*2187 + coLookup[i%2187][m]
+		})
+	})
+	solvedPerm, solvedFlip := toEdge6Coordinate(cubeSolved)
+	optimalEdges = make([]byte, (edge6StateCount+1)/2)
+	loadTable("optimal-edges", optimalEdges, func() {
+		buildPruneTable(optimalEdges, edge6StateCount, solvedPerm*64+solvedFlip, func(i, m int) int {
+			perm, flip := i/64, i%64
+			return int(edge6PermLookup[perm][m])*64 + (flip ^ int(edge6FlipLookup[perm][m]))
+		})
+	})
+}
+
+func initEdge6() {
+	edge6PermLookup = make([][moveCount]int32, edge6PermCount)
+	edge6FlipLookup = make([][moveCount]uint8, edge6PermCount)
+
+	// where the edge at each position goes, and whether it flips, for every move
+	var to, flips [moveCount][edgeCount]int
+	for m := 0; m < moveCount; m++ {
+		for i, e := range moves[m].Edges {
+			to[m][e.Index] = i
+			flips[m][e.Index] = e.Orientation
+		}
+	}
+
+	for i := 0; i < edge6PermCount; i++ {
+		positions := fromEdge6PermCoordinate(i)
+		for m := 0; m < moveCount; m++ {
+			var moved [edge6Tracked]int
+			flip := 0
+			for k, p := range positions {
+				moved[k] = to[m][p]
+				flip |= flips[m][p] << k
+			}
+			edge6PermLookup[i][m] = int32(toEdge6PermCoordinate(moved))
+			edge6FlipLookup[i][m] = uint8(flip)
+		}
+	}
+}
+
+// toEdge6PermCoordinate converts the positions of the 6 tracked edges
+// into a number from 0 to 12!/6!-1. Each position is ranked among the
+// positions not already taken by an earlier edge.
+func toEdge6PermCoordinate(positions [edge6Tracked]int) int {
+	result := 0
+	used := 0
+	for k, p := range positions {
+		rank := p
+		for q := 0; q < p; q++ {
+			if used&(1<<q) != 0 {
+				rank--
+			}
+		}
+		result = result*(edgeCount-k) + rank
+		used |= 1 << p
+	}
+	return result
+}
+
+// fromEdge6PermCoordinate converts a coordinate back into the positions
+// of the 6 tracked edges.
+func fromEdge6PermCoordinate(c int) [edge6Tracked]int {
+	var ranks [edge6Tracked]int
+	for k := edge6Tracked - 1; k >= 0; k-- {
+		ranks[k] = c % (edgeCount - k)
+		c /= edgeCount - k
+	}
+	var result [edge6Tracked]int
+	used := 0
+	for k, rank := range ranks {
+		p := 0
+		for ; ; p++ {
+			if used&(1<<p) != 0 {
+				continue
+			}
+			if rank == 0 {
+				break
+			}
+			rank--
+		}
+		result[k] = p
+		used |= 1 << p
+	}
+	return result
+}
+
+// toEdge6Coordinate finds the tracked edges in a cube and returns their
+// permutation and orientation coordinates.
+func toEdge6Coordinate(c Cube) (int, int) {
+	var positions [edge6Tracked]int
+	flip := 0
+	for i, e := range c.Edges {
+		if slot := edge6Slots[e.Index]; slot >= 0 {
+			positions[slot] = i
+			flip |= e.Orientation << slot
+		}
+	}
+	return toEdge6PermCoordinate(positions), flip
+}
+
+// buildPruneTable does a breadth first search out from the solved
+// coordinate to fill table with the min moves to reach it from every
+// coordinate below size.
+// next gives the coordinate reached by applying a move. The table is
+// packed two entries to a byte. Once most of the table is filled in it's
+// cheaper to search backwards: check each unvisited coordinate for a
+// neighbor at the current depth instead of expanding the current depth.
+func buildPruneTable(table []byte, size, solved int, next func(i, m int) int) {
+	for i := range table {
+		table[i] = 0xff
+	}
+	setNibble(table, solved, 0)
+	done := 1
+	for depth := 0; done < size; depth++ {
+		backward := done > size/2
+		for i := 0; i < size; i++ {
+			if backward {
+				if getNibble(table, i) != unvisited {
+					continue
+				}
+				for m := 0; m < moveCount; m++ {
+					if getNibble(table, next(i, m)) == depth {
+						setNibble(table, i, depth+1)
+						done++
+						break
+					}
+				}
+			} else {
+				if getNibble(table, i) != depth {
+					continue
+				}
+				for m := 0; m < moveCount; m++ {
+					j := next(i, m)
+					if getNibble(table, j) == unvisited {
+						setNibble(table, j, depth+1)
+						done++
+					}
+				}
+			}
+		}
+	}
+}
+
+func getNibble(table []byte, i int) int {
+	return int(table[i>>1]>>((i&1)<<2)) & 0xf
+}
+
+func setNibble(table []byte, i, v int) {
+	shift := (i & 1) << 2
+	table[i>>1] = table[i>>1]&^(0xf<<shift) | byte(v)<<shift
+}
+
+// optimalCoords is the position in the optimal search: the corner and
+// edge coordinates of the cube seen from each of the three axes.
+type optimalCoords struct {
+	cp, co, edgePerm, edgeFlip [3]int
+}
+
+func newOptimalCoords(c Cube) optimalCoords {
+	var result optimalCoords
+	for k, r := range axisRotations {
+		rotated := conjugate(c, r)
+		result.cp[k] = toCPCoordinate(rotated)
+		result.co[k] = toCOCoordinate(rotated)
+		result.edgePerm[k], result.edgeFlip[k] = toEdge6Coordinate(rotated)
+	}
+	return result
+}
+
+func (o optimalCoords) apply(m int) optimalCoords {
+	if m >= moveCount {
+		turns := sliceTurns[m-moveCount]
+		return o.apply(turns[0]).apply(turns[1])
+	}
+	var result optimalCoords
+	for k := range axisRotations {
+		km := axisMoves[k][m]
+		result.cp[k] = cpLookup[o.cp[k]][km]
+		result.co[k] = coLookup[o.co[k]][km]
+		result.edgePerm[k] = int(edge6PermLookup[o.edgePerm[k]][km])
+		result.edgeFlip[k] = o.edgeFlip[k] ^ int(edge6FlipLookup[o.edgePerm[k]][km])
+	}
+	return result
+}
+
+// heuristic is the max of the corner and edge tables over all three
+// axes, but it stops looking as soon as it reaches bound since the
+// search only needs to know whether a branch is worth exploring.
+func (o optimalCoords) heuristic(bound int) int {
+	result := 0
+	for k := range axisRotations {
+		corners := getNibble(optimalCorners, o.cp[k]*2187+o.co[k])
+		if corners > result {
+			result = corners
+		}
+		edges := getNibble(optimalEdges, o.edgePerm[k]*64+o.edgeFlip[k])
+		if edges > result {
+			result = edges
+		}
+		if result >= bound {
+			break
+		}
+	}
+	return result
+}
+
+// optimalBound is the heuristic in the solver's metric. The tables count
+// HTM moves, and in STM one move can do the work of two of them.
+func (s *solver) optimalBound(coords optimalCoords, bound int) int {
+	perMove := s.tables.movesPerHTM
+	return (coords.heuristic(bound*perMove) + perMove - 1) / perMove
+}
+
+// solveOptimal runs IDA*: a depth first search to a fixed depth, trying
+// every depth in turn, so the first solution found is the shortest.
+func (s *solver) solveOptimal() {
+	tablesOnce.Do(initTables)
+	optimalOnce.Do(initOptimal)
+
+	coords := newOptimalCoords(s.scrambledCube)
+	for depth := s.optimalBound(coords, moveCount); ; depth++ {
+		if s.searchOptimal(coords, depth) {
+			return
+		}
+	}
+}
+
+func (s *solver) searchOptimal(coords optimalCoords, depth int) bool {
+	s.nodes++
+	if s.cancelled() {
+		return true
+	}
+	if depth == 0 {
+		// every table reads 0 so this is almost certainly solved, but
+		// confirm it on the actual cube
+		c := s.scrambledCube
+		for _, m := range s.path {
+			c = transform(c, searchMoves[m])
+		}
+		if c != cubeSolved {
+			return false
+		}
+		s.recordSolution(append([]int(nil), s.path...), len(s.path))
+		return true
+	}
+	for _, m := range s.tables.phase1Moves {
+		// don't perform sequential moves of the same face, and only turn
+		// opposite faces in one order since they commute
+		if s.tables.redundant(s.path, m) || s.tables.outOfOrder(s.path, m) {
+			continue
+		}
+
+		next := coords.apply(m)
+		if s.optimalBound(next, depth) >= depth {
+			continue
+		}
+
+		s.path = append(s.path, m)
+		if s.searchOptimal(next, depth-1) {
+			return true
+		}
+		s.path = s.path[0 : len(s.path)-1]
+	}
+	return false
+}
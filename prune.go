@@ -1,6 +1,9 @@
 package cube
 
-import "math"
+import (
+	"math"
+	"sync"
+)
 
 // a bunch of lookup tables for each of the coordinate
 // spaces. We have a lookup table of each coordinate in
@@ -14,44 +17,123 @@ import "math"
 // spaces, we just use the lookup tables for moves to navigate around
 // the graph during search. We use the min moves tables as a hueristic
 // to guide the actual search algorithm towards good solutions.
-var eoLookup [2048][moveCount]int
-var coLookup [2187][moveCount]int
-var eSliceP1Lookup [495][moveCount]int
-var cpLookup [40320][moveCount]int
-var udLookup [40320][moveCount]int
-var eSliceP2Lookup [24][moveCount]int
+var eoLookup [2048][searchMoveCount]int
+var coLookup [2187][searchMoveCount]int
+var eSliceP1Lookup [495][searchMoveCount]int
+var cpLookup [40320][searchMoveCount]int
+var udLookup [40320][searchMoveCount]int
+var eSliceP2Lookup [24][searchMoveCount]int
 
 // in Phase 1 we are trying to orient all pieces and put the 4 e slice
-// edges in their slice (we don't care about where in the slice)
-// We will calculate the min moves to solve EO + ESlice and
-// CO + ESlice and we will use the max of the two as our heuristic
-var phase1EoAndSlice [2048 * 495]byte
-var phase1CoAndSlice [2187 * 495]byte
+// edges in their slice (we don't care about where in the slice).
+// Together EO, CO and ESlice describe everything phase 1 cares about,
+// so a table over all three gives the exact number of moves left in
+// phase 1. That is 2048 * 495 * 2187 entries, which is far too many, but
+// symmetric cubes are the same distance from the end of phase 1, so we
+// group the EO + ESlice ("flip slice") coordinates into classes of
+// symmetric coordinates (see symmetry.go) and only store one entry per
+// class for each CO.
+const flipSliceCount = 2048 * 495
+const flipSliceClassCount = 64430
+
+// flipSliceClass and flipSliceSym give the class of every flip slice
+// coordinate, and the symmetry which turns it into the representative
+// of its class. flipSliceRep is the representative of each class, and
+// flipSliceSelfSyms marks the symmetries which leave it unchanged.
+var flipSliceClass []uint16
+var flipSliceSym []uint8
+var flipSliceRep []uint32
+var flipSliceSelfSyms []uint16
+
+// coConj gives the CO coordinate of a cube as seen through each symmetry
+var coConj [2187][symCount]uint16
+
+// phase1Once guards the flip slice classes and coConj, which every
+// metric's phase1MinMoves is built on.
+//
+// phase1MinMoves holds the min moves to finish phase 1 for every flip
+// slice class and CO. The distances themselves don't fit in 2 bits, but
+// neighboring coordinates are never more than one move apart so it's
+// enough to store the distance mod 3 and track the actual distance as
+// we search, see phase1Distance.
+var phase1Once sync.Once
+
+const phase1TableSize = flipSliceClassCount * 2187
 
 // In phase2 we are trying to permute everything. We will calculate
 // the min moves to all all corners + all e slice edges and all edges
-// and use the max of the two as our heuristic
-var phase2CornerESliceMinMoves [40320 * 24]byte
-var phase2AllEdgesMinMoves [40320 * 24]byte
+// and use the max of the two as our heuristic. Both tables are
+// phase2TableSize entries, see metricTables.
+const phase2TableSize = 40320 * 24
 
-func init() {
+var tablesOnce sync.Once
+
+// initTables builds the move tables and phase 2 pruning tables which
+// every search relies on. They're left until the first solve so that
+// parsing, validating or printing cubes doesn't pay for them.
+func initTables() {
+	initSymmetries()
 	initEO()
 	initCO()
 	initESliceP1()
-	initCP()
-	initUD()
+	loadLookup("cp", cpLookup[:], initCP)
+	loadLookup("ud", udLookup[:], initUD)
 	initESliceP2()
-	initPhase2CornersESlice()
-	initPhase2Edges()
-	initPhase1COAndSlice()
-	initPhase1EOAndSlice()
+}
+
+// Prepare builds, or loads, every table the two phase search needs to
+// solve in HTM, the default metric. This otherwise happens during the
+// first solve, which can take several seconds, so call Prepare ahead of
+// time to keep that solve fast. It is safe to call more than once, and
+// from several goroutines.
+func Prepare() {
+	PrepareMetric(HTM)
+}
+
+// init builds, or loads, the metric's pruning tables.
+func (t *metricTables) init() {
+	t.phase2CornerESliceMinMoves = make([]byte, phase2TableSize)
+	loadTable("phase2-corners"+t.suffix, t.phase2CornerESliceMinMoves, func() {
+		t.buildPhase2Table(t.phase2CornerESliceMinMoves, cpLookup[:])
+	})
+	t.phase2AllEdgesMinMoves = make([]byte, phase2TableSize)
+	loadTable("phase2-edges"+t.suffix, t.phase2AllEdgesMinMoves, func() {
+		t.buildPhase2Table(t.phase2AllEdgesMinMoves, udLookup[:])
+	})
+	if t.group != nil {
+		t.initPhase1Restricted()
+		return
+	}
+	t.phase1MinMoves = make([]byte, (phase1TableSize+3)/4)
+	loadTable("phase1"+t.suffix, t.phase1MinMoves, t.initPhase1MinMoves)
+}
+
+// initPhase1Restricted builds the phase 1 tables for a restricted set of
+// moves. The flip slice classes only work for moves that look the same
+// through every symmetry, which a restricted set usually doesn't, so
+// instead there are two smaller tables, for EO and CO each with the
+// ESliceP1 coordinate. Together they give a lower bound on the moves
+// left in phase 1 rather than the exact number.
+func (t *metricTables) initPhase1Restricted() {
+	t.phase1EOSliceMinMoves = make([]byte, 2048*495)
+	loadTable("phase1-eo"+t.suffix, t.phase1EOSliceMinMoves, func() {
+		t.buildDistanceTable(t.phase1EOSliceMinMoves, t.phase1Moves, []int{0}, func(i, m int) int {
+			return eoLookup[i/495][m]*495 + eSliceP1Lookup[i%495][m]
+		})
+	})
+	t.phase1COSliceMinMoves = make([]byte, 2187*495)
+	loadTable("phase1-co"+t.suffix, t.phase1COSliceMinMoves, func() {
+		t.buildDistanceTable(t.phase1COSliceMinMoves, t.phase1Moves, []int{0}, func(i, m int) int {
+			return coLookup[i/495][m]*495 + eSliceP1Lookup[i%495][m]
+		})
+	})
 }
 
 func initEO() {
 	for i := 0; i < 2048; i++ {
 		c := fromEOCoordinate(i)
-		for j := 0; j < moveCount; j++ {
-			movedCube := transform(c, moves[j])
+		for j := 0; j < searchMoveCount; j++ {
+			movedCube := transform(c, searchMoves[j])
 			eoLookup[i][j] = toEOCoordinate(movedCube)
 		}
 	}
@@ -60,8 +142,8 @@ func initEO() {
 func initCO() {
 	for i := 0; i < 2187; i++ {
 		c := fromCOCoordinate(i)
-		for j := 0; j < moveCount; j++ {
-			movedCube := transform(c, moves[j])
+		for j := 0; j < searchMoveCount; j++ {
+			movedCube := transform(c, searchMoves[j])
 			coLookup[i][j] = toCOCoordinate(movedCube)
 		}
 	}
@@ -70,8 +152,8 @@ func initCO() {
 func initESliceP1() {
 	for i := 0; i < 495; i++ {
 		c := fromESliceP1Coordinate(i)
-		for j := 0; j < moveCount; j++ {
-			movedCube := transform(c, moves[j])
+		for j := 0; j < searchMoveCount; j++ {
+			movedCube := transform(c, searchMoves[j])
 			eSliceP1Lookup[i][j] = toESliceP1Coordinate(movedCube)
 		}
 	}
@@ -80,8 +162,8 @@ func initESliceP1() {
 func initCP() {
 	for i := 0; i < 40320; i++ {
 		c := fromCPCoordinate(i)
-		for j := 0; j < moveCount; j++ {
-			movedCube := transform(c, moves[j])
+		for j := 0; j < searchMoveCount; j++ {
+			movedCube := transform(c, searchMoves[j])
 			cpLookup[i][j] = toCPCoordinate(movedCube)
 		}
 	}
@@ -90,8 +172,8 @@ func initCP() {
 func initUD() {
 	for i := 0; i < 40320; i++ {
 		c := fromUDCoordinate(i)
-		for j := 0; j < moveCount; j++ {
-			movedCube := transform(c, moves[j])
+		for j := 0; j < searchMoveCount; j++ {
+			movedCube := transform(c, searchMoves[j])
 			udLookup[i][j] = toUDCoordinate(movedCube)
 		}
 	}
@@ -100,153 +182,235 @@ func initUD() {
 func initESliceP2() {
 	for i := 0; i < 24; i++ {
 		c := fromESliceP2Coordinate(i)
-		for j := 0; j < moveCount; j++ {
-			movedCube := transform(c, moves[j])
+		for j := 0; j < searchMoveCount; j++ {
+			movedCube := transform(c, searchMoves[j])
 			eSliceP2Lookup[i][j] = toESliceP2Coordinate(movedCube)
 		}
 	}
 }
 
-func initPhase1COAndSlice() {
-	queue := []int{0}
-	max := byte(0)
-	for len(queue) > 0 {
-		current := queue[0]
-		depth := phase1CoAndSlice[current]
-		queue = queue[1:]
-
-		eSliceP1Coord := current % 495
-		coCoord := current / 495
-
-		for i := 0; i < moveCount; i++ {
-			nextCo := coLookup[coCoord][i]
-			nextESlice := eSliceP1Lookup[eSliceP1Coord][i]
+// buildPhase2Table fills in the min cost to solve every combination of
+// a permutation coordinate, moved with lookup, and the ESliceP2
+// coordinate.
+func (t *metricTables) buildPhase2Table(table []byte, lookup [][searchMoveCount]int) {
+	t.buildDistanceTable(table, t.phase2Moves, []int{0}, func(i, m int) int {
+		return lookup[i/24][m]*24 + eSliceP2Lookup[i%24][m]
+	})
+}
 
-			next := nextCo*495 + nextESlice
-			if phase1CoAndSlice[next] == 0 && next != 0 {
-				phase1CoAndSlice[next] = 1 + depth
-				if phase1CoAndSlice[next] > max {
-					max = phase1CoAndSlice[next]
+// buildDistanceTable fills in the min cost to get to one of goals from
+// every entry of table, where next gives the entry that move m leads to. Moves can
+// cost 1 or 2, so rather than a plain breadth first search this keeps a
+// queue for every cost and works through them in order, which still
+// reaches every entry by its cheapest route first. Entries that can't
+// be reached are left at 0xff.
+func (t *metricTables) buildDistanceTable(table []byte, moves, goals []int, next func(i, m int) int) {
+	for i := range table {
+		table[i] = 0xff
+	}
+	for _, goal := range goals {
+		table[goal] = 0
+	}
+	queues := [][]int{goals}
+	for depth := 0; depth < len(queues); depth++ {
+		for _, current := range queues[depth] {
+			if int(table[current]) != depth {
+				// reached more cheaply after it was queued
+				continue
+			}
+			for _, m := range moves {
+				n := next(current, m)
+				nextDepth := depth + t.cost[m]
+				if nextDepth < int(table[n]) {
+					table[n] = byte(nextDepth)
+					for len(queues) <= nextDepth {
+						queues = append(queues, nil)
+					}
+					queues[nextDepth] = append(queues[nextDepth], n)
 				}
-				queue = append(queue, next)
 			}
 		}
+		queues[depth] = nil
 	}
 }
 
-func initPhase1EOAndSlice() {
-	queue := []int{0}
-	max := byte(0)
-	for len(queue) > 0 {
-		current := queue[0]
-		depth := phase1EoAndSlice[current]
-		queue = queue[1:]
+// phase2Hueristic combines the CP, UD and ESliceP2 coorindate spaces
+// to provide the lower bound in the same way we do in Phase 1.
+func (t *metricTables) phase2Hueristic(cpCoord, eudCood, eeCoord int) int {
+	cornerSliceCoord := cpCoord*24 + eeCoord
+	edgesCoord := eudCood*24 + eeCoord
+	return int(math.Max(
+		float64(t.phase2CornerESliceMinMoves[cornerSliceCoord]),
+		float64(t.phase2AllEdgesMinMoves[edgesCoord]),
+	))
+}
 
-		eSliceP1Coord := current % 495
-		eoCoord := current / 495
+func initPhase1() {
+	initFlipSliceClasses()
+	initCOConj()
+}
 
-		for i := 0; i < moveCount; i++ {
-			nextCo := eoLookup[eoCoord][i]
-			nextESlice := eSliceP1Lookup[eSliceP1Coord][i]
+// initFlipSliceClasses sorts every flip slice coordinate into a class
+// of coordinates which are symmetric to each other. The first coordinate
+// we come across in each class becomes its representative.
+func initFlipSliceClasses() {
+	flipSliceClass = make([]uint16, flipSliceCount)
+	flipSliceSym = make([]uint8, flipSliceCount)
+	flipSliceRep = make([]uint32, 0, flipSliceClassCount)
+	flipSliceSelfSyms = make([]uint16, 0, flipSliceClassCount)
 
-			next := nextCo*495 + nextESlice
-			if phase1EoAndSlice[next] == 0 && next != 0 {
-				phase1EoAndSlice[next] = 1 + depth
-				if phase1EoAndSlice[next] > max {
-					max = phase1EoAndSlice[next]
-				}
-				queue = append(queue, next)
+	seen := make([]bool, flipSliceCount)
+	for i := 0; i < flipSliceCount; i++ {
+		if seen[i] {
+			continue
+		}
+		class := len(flipSliceRep)
+		selfSyms := uint16(0)
+		c := fromFlipSliceCoordinate(i%2048, i/2048)
+		for sym := 0; sym < symCount; sym++ {
+			symmetric := symConjugate(c, sym)
+			j := toESliceP1Coordinate(symmetric)*2048 + toEOCoordinate(symmetric)
+			if j == i {
+				selfSyms |= 1 << sym
+			}
+			if !seen[j] {
+				seen[j] = true
+				flipSliceClass[j] = uint16(class)
+				// j is i seen through sym, so undoing sym gets back to i
+				flipSliceSym[j] = uint8(symInverse[sym])
 			}
 		}
+		flipSliceRep = append(flipSliceRep, uint32(i))
+		flipSliceSelfSyms = append(flipSliceSelfSyms, selfSyms)
 	}
 }
 
-func initPhase2CornersESlice() {
-	queue := []int{0}
-	max := byte(0)
-	for len(queue) > 0 {
-		current := queue[0]
-		depth := phase2CornerESliceMinMoves[current]
-		queue = queue[1:]
+func initCOConj() {
+	for i := 0; i < 2187; i++ {
+		c := fromCOCoordinate(i)
+		for sym := 0; sym < symCount; sym++ {
+			coConj[i][sym] = uint16(toCOCoordinate(symConjugate(c, sym)))
+		}
+	}
+}
+
+// phase1Index finds the entry of phase1MinMoves for a set of phase 1
+// coordinates by looking at them through the symmetry that takes the
+// flip slice coordinate to its class representative.
+func phase1Index(eoCoord, coCoord, ePermCoord int) int {
+	flipSlice := ePermCoord*2048 + eoCoord
+	return int(flipSliceClass[flipSlice])*2187 + int(coConj[coCoord][flipSliceSym[flipSlice]])
+}
 
-		eSliceP2Coord := current % 24
-		cpCoord := current / 24
+func (t *metricTables) initPhase1MinMoves() {
+	const size = phase1TableSize
+	// every entry starts out as 3, which marks it as not visited yet
+	for i := range t.phase1MinMoves {
+		t.phase1MinMoves[i] = 0xff
+	}
 
-		for i := 0; i < moveCount; i++ {
-			// don't do quarter turns on the side faces as these are not valid moves in phase 2
-			if i%3 != 1 && i > moveU3 && i < moveD {
-				continue
-			}
-			nextCp := cpLookup[cpCoord][i]
-			nextESlice := eSliceP2Lookup[eSliceP2Coord][i]
-
-			next := nextCp*24 + nextESlice
-			if phase2CornerESliceMinMoves[next] == 0 && next != 0 {
-				phase2CornerESliceMinMoves[next] = 1 + depth
-				if phase2CornerESliceMinMoves[next] > max {
-					max = phase2CornerESliceMinMoves[next]
+	done := 0
+	// set fills in an entry along with any entries which are the same
+	// cube seen through a symmetry of its flip slice representative
+	set := func(class, co, depth int) {
+		selfSyms := flipSliceSelfSyms[class]
+		for sym := 0; selfSyms != 0; sym++ {
+			if selfSyms&1 != 0 {
+				i := class*2187 + int(coConj[co][sym])
+				if t.getPhase1MinMoves(i) == 3 {
+					t.setPhase1MinMoves(i, depth%3)
+					done++
 				}
-				queue = append(queue, next)
 			}
+			selfSyms >>= 1
 		}
 	}
-}
-
-func initPhase2Edges() {
-	queue := []int{0}
-	max := byte(0)
-	for len(queue) > 0 {
-		current := queue[0]
-		depth := phase2AllEdgesMinMoves[current]
-		queue = queue[1:]
+	set(0, 0, 0)
 
-		eSliceP2Coord := current % 24
-		udCoord := current / 24
-
-		for i := 0; i < moveCount; i++ {
-			// don't do quarter turns on the side faces as these are not valid moves in phase 2
-			if i%3 != 1 && i > moveU3 && i < moveD {
+	for depth := 0; done < size; depth++ {
+		// once most of the table is filled in it's cheaper to look
+		// for a neighbor at the current depth from each unvisited entry
+		backward := done > size/2
+		for i := 0; i < size; i++ {
+			value := t.getPhase1MinMoves(i)
+			if backward && value != 3 || !backward && value != depth%3 {
 				continue
 			}
-			nextCp := udLookup[udCoord][i]
-			nextESlice := eSliceP2Lookup[eSliceP2Coord][i]
-
-			next := nextCp*24 + nextESlice
-			if phase2AllEdgesMinMoves[next] == 0 && next != 0 {
-				phase2AllEdgesMinMoves[next] = 1 + depth
-				if phase2AllEdgesMinMoves[next] > max {
-					max = phase2AllEdgesMinMoves[next]
+			class, co := i/2187, i%2187
+			rep := int(flipSliceRep[class])
+			eo, ePerm := rep%2048, rep/2048
+			for _, m := range t.phase1Moves {
+				next := phase1Index(eoLookup[eo][m], coLookup[co][m], eSliceP1Lookup[ePerm][m])
+				if backward {
+					if t.getPhase1MinMoves(next) == depth%3 {
+						set(class, co, depth+1)
+						break
+					}
+				} else if t.getPhase1MinMoves(next) == 3 {
+					set(next/2187, next%2187, depth+1)
 				}
-				queue = append(queue, next)
 			}
 		}
 	}
 }
 
-// phase1Hueristic combines the min moves for the EO space
-// the CO space and the E Slice Phase 1 spaces. We are attempting
-// to solve all three spaces simultaneously and we know the optimatl
-// number of moves required to solve each indepentantly. It follow from
-// there that the max of the three mins represents a lower bound for
-// possible solves - any branch in the search space that would cost
-// more than that lower bound is a branch not worth searching and can
-// be ignored or "pruned" from the search space.
-func phase1Hueristic(eoCoord, coCood, ePermCoord int) int {
-	coAndSlice := coCood*495 + ePermCoord
-	eoAnSlice := eoCoord*495 + ePermCoord
-	return int(math.Max(
-		float64(phase1EoAndSlice[eoAnSlice]),
-		float64(phase1CoAndSlice[coAndSlice]),
-	))
+func (t *metricTables) getPhase1MinMoves(i int) int {
+	return int(t.phase1MinMoves[i>>2]>>((i&3)<<1)) & 3
 }
 
-// phase2Hueristic combines the CP, UD and ESliceP2 coorindate spaces
-// to provide the lower bound in the same way we do in Phase 1.
-func phase2Hueristic(cpCoord, eudCood, eeCoord int) int {
-	cornerSliceCoord := cpCoord*24 + eeCoord
-	edgesCoord := eudCood*24 + eeCoord
-	return int(math.Max(
-		float64(phase2CornerESliceMinMoves[cornerSliceCoord]),
-		float64(phase2AllEdgesMinMoves[edgesCoord]),
-	))
+func (t *metricTables) setPhase1MinMoves(i, v int) {
+	shift := (i & 3) << 1
+	t.phase1MinMoves[i>>2] = t.phase1MinMoves[i>>2]&^(3<<shift) | byte(v)<<shift
+}
+
+// phase1Distance is the exact number of moves left in phase 1 after
+// making a move from a cube which was dist moves away. The move changes
+// the distance by at most one, so the distance mod 3 in the table is
+// enough to tell which way it went. For a restricted set of moves it's
+// only a lower bound, see initPhase1Restricted.
+func (t *metricTables) phase1Distance(eoCoord, coCoord, ePermCoord, dist int) int {
+	if t.phase1EOSliceMinMoves != nil {
+		return t.phase1LowerBound(eoCoord, coCoord, ePermCoord)
+	}
+	switch t.getPhase1MinMoves(phase1Index(eoCoord, coCoord, ePermCoord)) {
+	case (dist + 1) % 3:
+		return dist + 1
+	case dist % 3:
+		return dist
+	default:
+		return dist - 1
+	}
+}
+
+// phase1Start finds the exact number of moves left in phase 1 for the
+// starting cube, where we don't have a previous distance to go from.
+// We follow moves which bring the cube closer to the end of phase 1,
+// counting them, until we get there.
+func (t *metricTables) phase1Start(eoCoord, coCoord, ePermCoord int) int {
+	if t.phase1EOSliceMinMoves != nil {
+		return t.phase1LowerBound(eoCoord, coCoord, ePermCoord)
+	}
+	dist := 0
+	for eoCoord != 0 || coCoord != 0 || ePermCoord != 0 {
+		want := (t.getPhase1MinMoves(phase1Index(eoCoord, coCoord, ePermCoord)) + 2) % 3
+		for _, m := range t.phase1Moves {
+			eoNew := eoLookup[eoCoord][m]
+			coNew := coLookup[coCoord][m]
+			ePermNew := eSliceP1Lookup[ePermCoord][m]
+			if t.getPhase1MinMoves(phase1Index(eoNew, coNew, ePermNew)) == want {
+				eoCoord, coCoord, ePermCoord = eoNew, coNew, ePermNew
+				dist++
+				break
+			}
+		}
+	}
+	return dist
+}
+
+// phase1LowerBound is the larger of the two restricted phase 1 tables.
+func (t *metricTables) phase1LowerBound(eoCoord, coCoord, ePermCoord int) int {
+	return max(
+		int(t.phase1EOSliceMinMoves[eoCoord*495+ePermCoord]),
+		int(t.phase1COSliceMinMoves[coCoord*495+ePermCoord]),
+	)
 }
@@ -0,0 +1,168 @@
+package cube
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrNotReachable is returned when a cube can't be solved with the moves
+// given in SolveOptions.Moves.
+var ErrNotReachable = errors.New("the cube can't be solved with the allowed moves")
+
+// FaceTurns returns every turn of the faces written in faces, such as
+// "RUF", to restrict SolveOptions.Moves to those faces.
+func FaceTurns(faces string) ([]Move, error) {
+	var result []Move
+	for _, r := range faces {
+		face := strings.IndexRune(faceLetters, unicode.ToUpper(r))
+		if face < 0 {
+			return nil, fmt.Errorf("unknown face %q", r)
+		}
+		for turn := 0; turn < 3; turn++ {
+			result = append(result, Move(face*3+turn))
+		}
+	}
+	return result, nil
+}
+
+// restricted holds the tables for every restricted set of moves used so
+// far, by table name suffix.
+var restricted = struct {
+	sync.Mutex
+	tables map[string]*metricTables
+}{tables: make(map[string]*metricTables)}
+
+// searchTables returns the moves and tables to solve with the given
+// options.
+func searchTables(options SolveOptions) (*metricTables, error) {
+	if options.Metric < 0 || options.Metric >= metricCount {
+		return nil, fmt.Errorf("unknown metric %v", options.Metric)
+	}
+	if len(options.Moves) == 0 {
+		return metrics[options.Metric], nil
+	}
+	var allowed [moveCount]bool
+	mask := 0
+	for _, m := range options.Moves {
+		if m < 0 || m >= moveCount {
+			return nil, fmt.Errorf("invalid move %d", int(m))
+		}
+		allowed[m] = true
+		mask |= 1 << m
+	}
+	if err := checkFaces(options.Metric, allowed); err != nil {
+		return nil, err
+	}
+	suffix := fmt.Sprintf("%s-%05x", metrics[options.Metric].suffix, mask)
+
+	restricted.Lock()
+	defer restricted.Unlock()
+	t, ok := restricted.tables[suffix]
+	if !ok {
+		t = restrictedTables(options.Metric, allowed, suffix)
+		restricted.tables[suffix] = t
+	}
+	return t, nil
+}
+
+// checkFaces returns an error if a face's allowed turns can only be
+// combined by turning it twice in a row, which the search never does,
+// such as R without R' to undo it. QTM makes half turns out of two
+// quarter turns, so it needs both quarter turns and the half turn.
+func checkFaces(metric Metric, allowed [moveCount]bool) error {
+	for face := 0; face < 6; face++ {
+		quarter, half, inverse := allowed[face*3], allowed[face*3+1], allowed[face*3+2]
+		if quarter == inverse && half == quarter || metric != QTM && half && !quarter && !inverse {
+			continue
+		}
+		letter := faceLetters[face]
+		if metric == QTM {
+			return fmt.Errorf("%c: allow all three turns of a face in QTM, or none", letter)
+		}
+		return fmt.Errorf("%c: allow all three turns of a face, or only %c2, or none", letter, letter)
+	}
+	return nil
+}
+
+// restrictedTables sets up the search for a metric with only the allowed
+// moves. The tables themselves are built on the first two phase solve,
+// like any other metric's.
+//
+// The two phase search only works if the moves can reach every cube,
+// and the phase 2 moves among them can reach every cube in the phase 2
+// subgroup. Leaving out any one face keeps both, but take away much more
+// and they usually go, in which case the search falls back to the IDA*
+// of the optimal solver.
+func restrictedTables(metric Metric, allowed [moveCount]bool, suffix string) *metricTables {
+	// the orientations need axisMoves
+	tablesOnce.Do(initTables)
+
+	t := newMetricTables(metric)
+	t.suffix = suffix
+	isAllowed := func(m int) bool {
+		if m >= moveCount {
+			turns := sliceTurns[m-moveCount]
+			return allowed[turns[0]] && allowed[turns[1]]
+		}
+		return allowed[m]
+	}
+	t.phase1Moves = filterMoves(t.phase1Moves, isAllowed)
+	t.phase2Moves = filterMoves(t.phase2Moves, isAllowed)
+	t.inPhase2 = [searchMoveCount]bool{}
+	for _, m := range t.phase2Moves {
+		t.inPhase2[m] = true
+	}
+
+	t.group = newPermGroup(moveCubes(t.phase1Moves))
+	phase2Group := newPermGroup(moveCubes(t.phase2Moves))
+	for m := 0; m < moveCount; m++ {
+		if !t.group.contains(moves[m]) {
+			t.twoPhase = false
+		}
+	}
+	for _, m := range metrics[HTM].phase2Moves {
+		if !phase2Group.contains(moves[m]) {
+			t.twoPhase = false
+		}
+	}
+
+	// another orientation turns the moves into different moves, so it
+	// can only be searched if they're all still allowed. The inverse
+	// orientations invert them too.
+	t.orientations = nil
+	for o := 0; o < orientationCount; o++ {
+		back := &axisMoves[(3-o/2)%3]
+		ok := true
+		for m := 0; m < moveCount; m++ {
+			inverse := back[m]/3*3 + 2 - back[m]%3
+			if allowed[m] && (!allowed[back[m]] || o%2 == 1 && !allowed[inverse]) {
+				ok = false
+			}
+		}
+		if ok {
+			t.orientations = append(t.orientations, o)
+		}
+	}
+	return t
+}
+
+func filterMoves(ms []int, keep func(int) bool) []int {
+	var result []int
+	for _, m := range ms {
+		if keep(m) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func moveCubes(ms []int) []Cube {
+	result := make([]Cube, len(ms))
+	for i, m := range ms {
+		result[i] = searchMoves[m]
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package cube
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestrictedSolutionString(t *testing.T) {
+	const faces = "ULFRD"
+	allowed, err := FaceTurns(faces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, metric := range []Metric{HTM, QTM, STM} {
+		c := RandomCube(rng)
+		options := SolveOptions{
+			Metric:  metric,
+			Moves:   allowed,
+			Timeout: time.Second,
+		}
+		// the tables can take longer than the timeout to build
+		if err := PrepareOptions(options); err != nil {
+			t.Fatal(err)
+		}
+		solution, err := SolveWithOptions(context.Background(), c, options)
+		if err != nil {
+			t.Fatalf("%v: %v", metric, err)
+		}
+		s := solution.String()
+		// every layer the notation turns has to be one of the faces, and
+		// not a slice, a wide turn or a face moved by a rotation
+		for _, token := range strings.Fields(s) {
+			if !strings.ContainsRune(faces, rune(token[0])) {
+				t.Errorf("%v: %q turns %s", metric, s, token)
+			}
+		}
+		moves, err := ParseMoves(s)
+		if err != nil {
+			t.Fatalf("%v: %q: %v", metric, s, err)
+		}
+		if !c.ApplySequence(moves).IsSolved() {
+			t.Errorf("%v: %q doesn't solve the cube", metric, s)
+		}
+	}
+}
+
+func TestRestrictedMovesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric Metric
+		moves  []Move
+	}{
+		{"quarter turns only", HTM, []Move{MoveR, MoveU}},
+		{"no half turn", HTM, []Move{MoveR, MoveR3, MoveU, MoveU3}},
+		{"no inverse", STM, []Move{MoveR, MoveR2, MoveU, MoveU2}},
+		{"half turns in QTM", QTM, []Move{MoveR2, MoveU, MoveU2, MoveU3}},
+	}
+	c := cubeSolved.Apply(MoveR).Apply(MoveU)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SolveWithOptions(context.Background(), c, SolveOptions{Metric: tt.metric, Moves: tt.moves})
+			if err == nil || errors.Is(err, ErrNotReachable) {
+				t.Errorf("got %v, want an error for the moves", err)
+			}
+		})
+	}
+
+	// half turns alone are fine, since they undo themselves
+	moves := []Move{MoveU, MoveU2, MoveU3, MoveD, MoveD2, MoveD3, MoveR2, MoveL2, MoveF2, MoveB2}
+	c = cubeSolved.Apply(MoveR2).Apply(MoveU).Apply(MoveF2)
+	options := SolveOptions{Moves: moves, Timeout: time.Second}
+	if err := PrepareOptions(options); err != nil {
+		t.Fatal(err)
+	}
+	solution, err := SolveWithOptions(context.Background(), c, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ApplySequence(solution.Moves).IsSolved() {
+		t.Errorf("%v doesn't solve the cube", solution)
+	}
+}
@@ -0,0 +1,86 @@
+package cube
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RandomCube returns a cube picked uniformly at random from every cube
+// that can be reached by turning faces. Every random choice is drawn
+// from rng, so the same seed always gives the same cube. A nil rng is
+// seeded from the clock.
+func RandomCube(rng *rand.Rand) Cube {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	var c Cube
+	edges := rng.Perm(edgeCount)
+	corners := rng.Perm(cornerCount)
+	// any arrangement of the pieces is reachable so long as the edge
+	// and corner permutations have the same parity. Swapping two edges
+	// fixes a mismatch and pairs every cube with exactly one other, so
+	// this doesn't favor any cube over another.
+	if permutationParity(edges) != permutationParity(corners) {
+		edges[0], edges[1] = edges[1], edges[0]
+	}
+
+	// the orientations are free apart from the last piece of each kind,
+	// which has to make the totals come out to a multiple of 2 and 3
+	flips, twists := 0, 0
+	for i := range c.Edges {
+		c.Edges[i] = Piece{Index: edges[i]}
+		if i < edgeCount-1 {
+			c.Edges[i].Orientation = rng.Intn(2)
+			flips += c.Edges[i].Orientation
+		} else {
+			c.Edges[i].Orientation = flips % 2
+		}
+	}
+	for i := range c.Corners {
+		c.Corners[i] = Piece{Index: corners[i]}
+		if i < cornerCount-1 {
+			c.Corners[i].Orientation = rng.Intn(3)
+			twists += c.Corners[i].Orientation
+		} else {
+			c.Corners[i].Orientation = (3 - twists%3) % 3
+		}
+	}
+	return c
+}
+
+// RandomScramble returns a random state scramble in the style used at
+// WCA competitions: a uniformly random cube is drawn with RandomCube,
+// solved with the two phase search, and the solution is inverted. As
+// the WCA requires, cubes which are solved or one move from solved are
+// thrown away and drawn again. The same seed always gives the same
+// scramble.
+func RandomScramble(rng *rand.Rand) ([]Move, error) {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	c := RandomCube(rng)
+	for nearlySolved(c) {
+		c = RandomCube(rng)
+	}
+	// a single goroutine with no time limit searches in the same order
+	// every time, so the same cube always gets the same solution
+	solution, err := SolveWithOptions(context.Background(), c, SolveOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return InvertMoves(solution.Moves), nil
+}
+
+// nearlySolved reports whether a cube is less than two moves from solved.
+func nearlySolved(c Cube) bool {
+	if c == cubeSolved {
+		return true
+	}
+	for _, m := range moves {
+		if c == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package cube
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRandomCube(t *testing.T) {
+	a := RandomCube(rand.New(rand.NewSource(1)))
+	b := RandomCube(rand.New(rand.NewSource(1)))
+	if a != b {
+		t.Errorf("the same seed gave %v and %v", a, b)
+	}
+	if c := RandomCube(rand.New(rand.NewSource(2))); c == a {
+		t.Errorf("seeds 1 and 2 both gave %v", c)
+	}
+
+	// every piece should turn up in every position, in every orientation,
+	// about as often as any other, including the last of each kind whose
+	// orientation is fixed by the rest
+	const n = 24000
+	rng := rand.New(rand.NewSource(1))
+	var edges [edgeCount][edgeCount * 2]int
+	var corners [cornerCount][cornerCount * 3]int
+	for i := 0; i < n; i++ {
+		c := RandomCube(rng)
+		if err := c.Validate(); err != nil {
+			t.Fatalf("%v: %v", c, err)
+		}
+		for pos, e := range c.Edges {
+			edges[pos][e.Index*2+e.Orientation]++
+		}
+		for pos, corner := range c.Corners {
+			corners[pos][corner.Index*3+corner.Orientation]++
+		}
+	}
+	check := func(kind string, pos int, counts []int) {
+		want := n / len(counts)
+		for state, count := range counts {
+			if count < want*3/4 || count > want*5/4 {
+				t.Errorf("%s %d is in state %d %d times, want around %d", kind, pos, state, count, want)
+			}
+		}
+	}
+	for pos := range edges {
+		check("edge", pos, edges[pos][:])
+	}
+	for pos := range corners {
+		check("corner", pos, corners[pos][:])
+	}
+}
+
+func TestRandomScramble(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		a, err := RandomScramble(rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := RandomScramble(rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FormatMoves(a) != FormatMoves(b) {
+			t.Errorf("seed %d gave %q and %q", seed, FormatMoves(a), FormatMoves(b))
+		}
+		// the scramble has to make the cube RandomCube drew
+		want := RandomCube(rand.New(rand.NewSource(seed)))
+		if c := cubeSolved.ApplySequence(a); c != want {
+			t.Errorf("seed %d: %q doesn't make %v", seed, FormatMoves(a), want)
+		}
+	}
+}
+
+func TestNearlySolved(t *testing.T) {
+	if !nearlySolved(cubeSolved) {
+		t.Error("solved isn't nearly solved")
+	}
+	for _, m := range []Move{MoveU, MoveR2, MoveB3} {
+		if !nearlySolved(cubeSolved.Apply(m)) {
+			t.Errorf("%v isn't nearly solved", m)
+		}
+	}
+	if nearlySolved(cubeSolved.Apply(MoveR).Apply(MoveU)) {
+		t.Error("R U is nearly solved")
+	}
+}
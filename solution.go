@@ -0,0 +1,182 @@
+package cube
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Solution is the result of solving a cube.
+type Solution struct {
+	// Moves is the full solution, phase 1 followed by phase 2
+	Moves []Move
+	// Phase1Length is the number of moves at the start of Moves which
+	// bring the cube into the phase 2 subgroup. Optimal solutions have
+	// only one phase, so it is the length of the whole solution.
+	Phase1Length int
+	// Inverse is set when the solution was found by solving the inverse
+	// of the cube. Moves is then that search's solution undone, so the
+	// phase 2 moves come first and the phase 1 moves are at the end.
+	Inverse bool
+	// Metric is the metric the search kept the solution short in
+	Metric Metric
+	// Nodes is the number of search nodes expanded across both phases
+	Nodes int64
+	// Duration is the time spent searching
+	Duration time.Duration
+
+	// faceTurns is set when the search was restricted to some faces, so
+	// an STM solution is written with face turns rather than in SiGN
+	faceTurns bool
+}
+
+func newSolution(path []int, phase1Length int) Solution {
+	moves := make([]Move, len(path))
+	for i, m := range path {
+		moves[i] = Move(m)
+	}
+	return Solution{
+		Moves:        moves,
+		Phase1Length: phase1Length,
+	}
+}
+
+// Phase1 returns the moves of the first phase of the solution.
+func (s Solution) Phase1() []Move {
+	if s.Inverse {
+		return s.Moves[len(s.Moves)-s.Phase1Length:]
+	}
+	return s.Moves[:s.Phase1Length]
+}
+
+// Phase2 returns the moves of the second phase of the solution.
+func (s Solution) Phase2() []Move {
+	if s.Inverse {
+		return s.Moves[:len(s.Moves)-s.Phase1Length]
+	}
+	return s.Moves[s.Phase1Length:]
+}
+
+// HTM returns the length of the solution in the half turn metric,
+// where every face turn counts as one move.
+func (s Solution) HTM() int {
+	return len(s.Moves)
+}
+
+// QTM returns the length of the solution in the quarter turn metric,
+// where half turns count as two moves.
+func (s Solution) QTM() int {
+	result := 0
+	for _, m := range s.Moves {
+		if m%3 == 1 {
+			result += 2
+		} else {
+			result++
+		}
+	}
+	return result
+}
+
+// STM returns the length of the solution in the slice turn metric. Two
+// consecutive turns of opposite faces in the same direction relative
+// to the slice between them, e.g. R L', are a single slice turn.
+func (s Solution) STM() int {
+	result := 0
+	for i := 0; i < len(s.Moves); i++ {
+		result++
+		if i+1 < len(s.Moves) && isSliceTurn(s.Moves[i], s.Moves[i+1]) {
+			i++
+		}
+	}
+	return result
+}
+
+// Length returns the length of the solution in a metric.
+func (s Solution) Length(metric Metric) int {
+	switch metric {
+	case QTM:
+		return s.QTM()
+	case STM:
+		return s.STM()
+	}
+	return s.HTM()
+}
+
+// isSliceTurn reports whether two consecutive face turns are the same
+// as turning the slice between them.
+func isSliceTurn(a, b Move) bool {
+	if oppositeFace[a/3] != int(b/3) {
+		return false
+	}
+	return (a%3+1)+(b%3+1) == 4
+}
+
+// oppositeFace maps each face, in move order U L F R B D, to the face
+// on the other side of the cube
+var oppositeFace = [6]int{5, 3, 4, 1, 2, 0}
+
+// String returns the solution in standard notation with the moves
+// separated by spaces. STM solutions are written in SiGN, with slice
+// turns, see FormatSiGN, unless SolveOptions.Moves restricted the search.
+// A slice turn moves the centers, and with them the layer that every
+// later face letter turns, so the solution would no longer keep to the
+// allowed faces.
+func (s Solution) String() string {
+	if s.Metric == STM && !s.faceTurns {
+		return FormatSiGN(s.Moves)
+	}
+	return FormatMoves(s.Moves)
+}
+
+// MarshalJSON encodes the solution along with its length in each metric.
+func (s Solution) MarshalJSON() ([]byte, error) {
+	moves := s.Moves
+	if moves == nil {
+		moves = []Move{}
+	}
+	return json.Marshal(struct {
+		Solution     string  `json:"solution"`
+		Moves        []Move  `json:"moves"`
+		Phase1Length int     `json:"phase1Length"`
+		Inverse      bool    `json:"inverse"`
+		Metric       string  `json:"metric"`
+		HTM          int     `json:"htm"`
+		QTM          int     `json:"qtm"`
+		STM          int     `json:"stm"`
+		Nodes        int64   `json:"nodes"`
+		DurationMS   float64 `json:"durationMs"`
+	}{
+		Solution:     s.String(),
+		Moves:        moves,
+		Phase1Length: s.Phase1Length,
+		Inverse:      s.Inverse,
+		Metric:       s.Metric.String(),
+		HTM:          s.HTM(),
+		QTM:          s.QTM(),
+		STM:          s.STM(),
+		Nodes:        s.Nodes,
+		DurationMS:   float64(s.Duration) / float64(time.Millisecond),
+	})
+}
+
+// MarshalText encodes the move in standard notation, so moves appear
+// as strings such as "R'" in JSON.
+func (m Move) MarshalText() ([]byte, error) {
+	if m < 0 || m >= moveCount {
+		return nil, fmt.Errorf("invalid move %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText decodes a single move in standard notation.
+func (m *Move) UnmarshalText(text []byte) error {
+	path, err := parseMoves(string(text))
+	if err != nil {
+		return err
+	}
+	if len(path) != 1 {
+		return fmt.Errorf("expected a single move, got %q", text)
+	}
+	*m = Move(path[0])
+	return nil
+}
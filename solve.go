@@ -1,102 +1,556 @@
 package cube
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// checking the context for cancellation is relatively expensive
+// compared to expanding a node, so only do it every so often
+const cancelCheckInterval = 1 << 12
+
+// SolveOptions tune the search. The search finds a solution
+// quickly and then keeps looking for shorter ones until it finds one of
+// MaxLength moves or less, or runs out of time or nodes, whichever comes
+// first. Zero values use the defaults.
+type SolveOptions struct {
+	// Metric is how solution length is measured, and so what the search
+	// keeps short. Defaults to HTM. The first solve in a metric builds
+	// that metric's tables, see PrepareMetric.
+	Metric Metric
+	// Moves, if set, restricts the search to these face turns, for
+	// example to leave out the B face for a robot with no gripper there.
+	// Each set of moves builds its own tables on its first solve, and a
+	// cube the moves can't reach gets ErrNotReachable. The two phase
+	// search needs moves that can reach any cube, which any five faces
+	// can, otherwise the optimal search is used instead, which can be
+	// very slow so set a Timeout. The search never turns a face twice
+	// in a row, so each face needs all three of its turns, or just the
+	// half turn, or none, and other sets are an error. In QTM half turns
+	// are made of quarter turns, so a face needs all three or none, and
+	// in STM a slice turn needs both faces either side of it and the
+	// solution is written with face turns, see Solution.String.
+	Moves []Move
+	// Mask, if set, only solves the parts of the cube it marks, such as
+	// the cross, and finds the shortest way to do so with an IDA* search.
+	// Each mask builds its own tables on its first solve, see
+	// PrepareOptions, and keeps them for as long as the program runs.
+	// The search keeps going until it finds a shortest solution, so
+	// MaxLength, MaxPhase2Depth and Optimal don't apply, and a mask which
+	// leaves little of the cube out can take as long as an optimal solve.
+	Mask *Mask
+	// MaxLength is the solution length, in moves of the metric, at which
+	// the search stops looking for anything shorter. Defaults to 21 in
+	// HTM, 27 in QTM and 20 in STM. A large value returns the first solution found,
+	// which is the fastest; a small value keeps searching for a short
+	// solution.
+	MaxLength int
+	// MaxPhase2Depth limits the length of phase 2 solutions, in moves of
+	// the metric. Deep phase 2 searches are expensive and a long phase 2
+	// is rarely part of a short solution. Defaults to 10 in HTM and 16 in
+	// QTM.
+	MaxPhase2Depth int
+	// Timeout is the time budget for the search, after which the best
+	// solution found so far is returned. Zero means no limit.
+	Timeout time.Duration
+	// MaxNodes is the node budget for the search, after which the best
+	// solution found so far is returned. Zero means no limit. Each
+	// goroutine only adds its nodes to the count every 4096 nodes, so
+	// the search can go up to that many nodes per worker past the
+	// budget before it stops. Solution.Nodes is always exact.
+	MaxNodes int64
+	// Optimal switches from the two phase search to an optimal search,
+	// which finds a shortest possible solution in the metric. It takes
+	// seconds for a cube up to around 14 moves from solved, but each move
+	// further multiplies the time by more than ten, so a random cube,
+	// usually 17 or 18 moves from solved, can take hours or more. Set
+	// Timeout or MaxNodes to bound it. The first optimal solve builds
+	// around 125MB of tables. MaxLength and MaxPhase2Depth don't apply.
+	Optimal bool
+	// Workers is the number of goroutines the two phase search is split
+	// across. Each goroutine takes a share of the phase 1 search, by
+	// orientation and first move, and they all share the best solution
+	// found so far so that none of them waste time on anything longer.
+	// Defaults to 1.
+	// The optimal search always runs on a single goroutine.
+	Workers int
+	// OnSolution, if set, is called with every solution as it is found.
+	// Each solution is shorter than the one before, so callers can show
+	// a solution straight away and replace it as better ones come in.
+	// It is called from a searching goroutine, so it should return
+	// quickly. Calls are never made concurrently.
+	OnSolution func(Solution)
+}
+
+func (o SolveOptions) withDefaults() SolveOptions {
+	if o.MaxLength <= 0 {
+		o.MaxLength = 21
+		switch o.Metric {
+		case QTM:
+			o.MaxLength = 27
+		case STM:
+			o.MaxLength = 20
+		}
+	}
+	if o.MaxPhase2Depth <= 0 {
+		o.MaxPhase2Depth = 10
+		if o.Metric == QTM {
+			o.MaxPhase2Depth = 16
+		}
+	}
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	return o
+}
+
+// searchState is shared by every goroutine working on the same solve.
+type searchState struct {
+	mu          sync.Mutex
+	solution    Solution
+	minSolution atomic.Int64
+	stopped     atomic.Bool
+	nodes       atomic.Int64
+	start       time.Time
+}
+
 type solver struct {
-	scrambledCube cube
+	ctx           context.Context
+	options       SolveOptions
+	shared        *searchState
+	tables        *metricTables
+	masked        *maskTables
+	scrambledCube Cube
+	orientation   int
 	path          []int
 	pathPhase2    []int
-	minSolution   int
-	solution      string
-	maxLength     int
+	nodes         int64
 }
 
-func newSolver(scramble string) (*solver, error) {
-	c, err := parseScrambe(scramble)
-	if err != nil {
-		return nil, err
-	}
+func newSolver(ctx context.Context, c Cube, options SolveOptions, tables *metricTables) *solver {
+	shared := &searchState{}
+	shared.minSolution.Store(999)
 	return &solver{
+		ctx:           ctx,
+		options:       options.withDefaults(),
+		shared:        shared,
+		tables:        tables,
 		path:          make([]int, 0),
 		pathPhase2:    make([]int, 0),
 		scrambledCube: c,
-		minSolution:   999,
-		maxLength:     21,
-	}, nil
+	}
+}
+
+// fork creates a solver for another goroutine working on the same solve.
+func (s *solver) fork() *solver {
+	return &solver{
+		ctx:           s.ctx,
+		options:       s.options,
+		shared:        s.shared,
+		tables:        s.tables,
+		path:          make([]int, 0),
+		pathPhase2:    make([]int, 0),
+		scrambledCube: s.scrambledCube,
+	}
+}
+
+// minSolution is the cost of the best solution found so far, or 999
+// if there isn't one yet.
+func (s *solver) minSolution() int {
+	return int(s.shared.minSolution.Load())
+}
+
+// Solve finds a solution for the cube produced by the given scramble.
+// The first solve also builds the pruning tables, which takes several
+// seconds unless they've been saved from an earlier run, see Prepare.
+func Solve(scrambe string) (Solution, error) {
+	c, err := parseScrambe(scrambe)
+	if err != nil {
+		return Solution{}, err
+	}
+	return SolveCube(c)
+}
+
+// SolveCube finds a solution for a cube in any state, for example one
+// read in with FromFacelets. Cubes that can't be solved are rejected
+// with the error from Validate.
+func SolveCube(c Cube) (Solution, error) {
+	return SolveContext(context.Background(), c)
+}
+
+// SolveContext is like SolveCube but stops searching once ctx is done.
+// The search keeps finding shorter solutions the longer it runs, so if
+// it is stopped early the best solution found so far is returned. If
+// no solution was found in time the context's error is returned.
+func SolveContext(ctx context.Context, c Cube) (Solution, error) {
+	return SolveWithOptions(ctx, c, SolveOptions{})
 }
 
-func Solve(scrambe string) (string, error) {
-	solver, err := newSolver(scrambe)
+// SolveWithOptions is like SolveContext but allows the search to be
+// tuned. If the time or node budget runs out before any solution is
+// found ErrBudgetExceeded is returned, or the context's error if it was
+// ctx that ran out, along with a Solution with no moves whose Nodes and
+// Duration say how much searching was done.
+func SolveWithOptions(ctx context.Context, c Cube, options SolveOptions) (Solution, error) {
+	if err := c.Validate(); err != nil {
+		return Solution{}, err
+	}
+	tables, err := searchTables(options)
 	if err != nil {
-		return "", err
+		return Solution{}, err
+	}
+	var masked *maskTables
+	if options.Mask != nil {
+		// the moves only need to reach the part of the cube that's masked
+		masked = tablesForMask(*options.Mask, tables)
+		if masked.bound(masked.state(c), 0xff) == 0xff {
+			return Solution{}, ErrNotReachable
+		}
+	} else if tables.group != nil && !tables.group.contains(c) {
+		return Solution{}, ErrNotReachable
+	}
+	searchCtx := ctx
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+	s := newSolver(searchCtx, c, options, tables)
+	s.masked = masked
+	solution := s.solve()
+	if s.minSolution() == 999 && s.shared.stopped.Load() {
+		// solution has no moves, but still says how far the search got
+		if err := contextErr(ctx); err != nil {
+			return solution, err
+		}
+		return solution, ErrBudgetExceeded
 	}
-	solution := solver.solve()
 	return solution, nil
 }
 
-func (s *solver) solve() string {
-	// convert the cube into the 3 phase 1 coordinates:
-	eoCoord := toEOCoordinate(s.scrambledCube)
-	coCoord := toCOCoordinate(s.scrambledCube)
-	ePermCoord := toESliceP1Coordinate(s.scrambledCube)
+// PrepareOptions is Prepare for solving with the given options: it builds,
+// or loads, the tables for the metric, the restricted moves, the mask or
+// the optimal search, whichever the options need. Tables for restricted
+// moves or a mask are otherwise built in the middle of the first solve
+// that uses them, and the optimal search's take 15 seconds or so, so a
+// server can call this at startup for every set of options it accepts.
+// It returns the error a solve with the options would.
+func PrepareOptions(options SolveOptions) error {
+	tables, err := searchTables(options)
+	switch {
+	case err != nil:
+		return err
+	case options.Mask != nil:
+		tablesForMask(*options.Mask, tables)
+	case options.Optimal || !tables.twoPhase:
+		tablesOnce.Do(initTables)
+		optimalOnce.Do(initOptimal)
+	default:
+		tables.prepare()
+	}
+	return nil
+}
+
+// SolveTo finds moves which take the cube from one state to another,
+// rather than to solved, for example to make a pattern out of a cube
+// that's already scrambled. Both cubes are checked with Validate.
+func SolveTo(from, to Cube) (Solution, error) {
+	return SolveToWithOptions(context.Background(), from, to, SolveOptions{})
+}
+
+// SolveToWithOptions is SolveTo with the context and options of
+// SolveWithOptions.
+func SolveToWithOptions(ctx context.Context, from, to Cube, options SolveOptions) (Solution, error) {
+	if err := from.Validate(); err != nil {
+		return Solution{}, fmt.Errorf("from: %w", err)
+	}
+	if err := to.Validate(); err != nil {
+		return Solution{}, fmt.Errorf("to: %w", err)
+	}
+	// the moves we want are the cube from⁻¹·to, and a solution of a cube
+	// is its inverse, so solve to⁻¹·from
+	return SolveWithOptions(ctx, transform(to.Inverse(), from), options)
+}
+
+// ErrBudgetExceeded is returned when the time or node budget given in
+// SolveOptions runs out before any solution is found.
+var ErrBudgetExceeded = errors.New("search budget exceeded before a solution was found")
+
+// cancelled reports whether the search should stop, because a good
+// enough solution was found, the context is done or the node budget is
+// spent. Once stopped, every level of the search unwinds. Nodes are
+// added to the shared count in batches to keep goroutines from fighting
+// over it.
+func (s *solver) cancelled() bool {
+	if s.nodes%cancelCheckInterval == 0 {
+		nodes := s.shared.nodes.Add(cancelCheckInterval)
+		if s.options.MaxNodes > 0 && nodes > s.options.MaxNodes || contextErr(s.ctx) != nil {
+			s.shared.stopped.Store(true)
+		}
+	}
+	return s.shared.stopped.Load()
+}
+
+// contextErr is ctx.Err, but it also reports a deadline which has passed
+// before the context's timer has fired. Under js/wasm the search never
+// yields to the event loop, so the timer doesn't fire until it's over.
+func contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// totalNodes is the number of nodes expanded by every goroutine so far.
+func (s *solver) totalNodes() int64 {
+	return s.shared.nodes.Load() + s.nodes%cancelCheckInterval
+}
+
+func (s *solver) solve() Solution {
+	s.shared.start = time.Now()
+	switch {
+	case s.masked != nil:
+		s.solveMasked()
+	case s.options.Optimal || !s.tables.twoPhase:
+		s.solveOptimal()
+	default:
+		s.solveTwoPhase()
+	}
+	solution := s.shared.solution
+	solution.Nodes = s.totalNodes()
+	solution.Duration = time.Since(s.shared.start)
+	return solution
+}
+
+// The two phase search looks at the cube from six orientations: with
+// each of the UD, RL and FB axes rotated round to where UD was, and the
+// same three again for the inverse of the cube. Phase 1 only ever aims at
+// the UD axis so the six searches go different ways and turn up different
+// solutions, and the shortest of them is usually a move or two shorter
+// than searching one orientation for as long. Orientation o is
+// axisRotations[o/2], of the inverse when o is odd.
+const orientationCount = 6
+
+// phase1Root is where the phase 1 search starts for one orientation.
+type phase1Root struct {
+	orientation         int
+	cube                Cube
+	eo, co, ePerm, cost int
+}
+
+func newPhase1Roots(c Cube, t *metricTables) []phase1Root {
+	roots := make([]phase1Root, 0, len(t.orientations))
+	for _, o := range t.orientations {
+		oriented := conjugate(c, axisRotations[o/2])
+		if o%2 == 1 {
+			oriented = oriented.Inverse()
+		}
+		// convert the cube into the 3 phase 1 coordinates:
+		eo := toEOCoordinate(oriented)
+		co := toCOCoordinate(oriented)
+		ePerm := toESliceP1Coordinate(oriented)
+		roots = append(roots, phase1Root{
+			orientation: o,
+			cube:        oriented,
+			eo:          eo,
+			co:          co,
+			ePerm:       ePerm,
+			cost:        t.phase1Start(eo, co, ePerm),
+		})
+	}
+	return roots
+}
+
+// orient points the solver at the cube as seen from a root's orientation.
+// Solutions it finds are mapped back to the original cube when recorded.
+func (s *solver) orient(root *phase1Root) {
+	s.scrambledCube = root.cube
+	s.orientation = root.orientation
+}
+
+// fromOrientation maps a solution of the cube seen from orientation o
+// back to a solution of the original cube.
+func fromOrientation(path []int, o int) []int {
+	// a move on the rotated cube is the move rotated the other way round
+	// on the original
+	back := &axisMoves[(3-o/2)%3]
+	result := make([]int, len(path))
+	for i, m := range path {
+		result[i] = back[m]
+	}
+	if o%2 == 1 {
+		// the search solved the inverse, so its solution is a scramble of
+		// the original cube. Undo it by reversing it and inverting every
+		// move.
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+		for i, m := range result {
+			result[i] = m/3*3 + 2 - m%3
+		}
+	}
+	return result
+}
+
+func (s *solver) solveTwoPhase() {
+	s.tables.prepare()
+	roots := newPhase1Roots(s.scrambledCube, s.tables)
+	minCost := roots[0].cost
+	for _, root := range roots {
+		minCost = min(minCost, root.cost)
+	}
 
-	cost := phase1Hueristic(eoCoord, coCoord, ePermCoord)
+	// our phase 1 table gives the exact number of moves to finish
+	// phase 1, so the first search finds the shortest phase 1
+	// solutions. we do not stop with the first phase 1 solution we
+	// find though. we continue to find sub-optimal solutions (up to
+	// 20 moves) for phase1 in hopes that we find one that "sets up" a
+	// good phase 2 and provides a generally efficient solve. Each depth
+	// is searched from every orientation before moving on to the next.
+	if s.options.Workers > 1 {
+		s.searchParallel(roots, minCost)
+		return
+	}
+	for i := minCost; i < 20; i++ {
+		for r := range roots {
+			if roots[r].cost > i {
+				continue
+			}
+			s.orient(&roots[r])
+			if s.search(roots[r].eo, roots[r].co, roots[r].ePerm, roots[r].cost, i) {
+				return
+			}
+		}
+	}
+}
 
-	// because our hueristic find the lower bound for possible
-	// solves, it might actually under estimate. So if we don't
-	// actually find any solves in our cost, we will just
-	// keep bumping the estimated cost until we do find a solution.
-	// we also do not stop with the first phase 1 solution we find.
-	// we continue to find sub-optimal solutions (up to 20 moves) for
-	// phase1 in hopes that we find one that "sets up" a good phase 2
-	// and provides a generally efficient solve.
-	for i := cost; i < 20; i++ {
-		done := s.search(eoCoord, coCoord, ePermCoord, cost, i)
-		if done {
-			break
+// searchParallel splits the phase 1 search between worker goroutines.
+// Each depth of the search is cut up by orientation and first move, and
+// the workers take these pieces in order so that shallower searches are
+// done first, just like the single goroutine search. Any solution found
+// by one worker limits the phase 2 searches of all the others.
+func (s *solver) searchParallel(roots []phase1Root, minCost int) {
+	type job struct {
+		root       *phase1Root
+		phase1Cost int
+		// move is the first move to search, or -1 to search the root
+		// itself when it's already in the phase 2 subgroup
+		move int
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < s.options.Workers; w++ {
+		worker := s.fork()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if worker.shared.stopped.Load() {
+					continue
+				}
+				root := j.root
+				worker.orient(root)
+				if j.move < 0 {
+					worker.search(root.eo, root.co, root.ePerm, root.cost, j.phase1Cost)
+				} else {
+					worker.searchMove(root.eo, root.co, root.ePerm, root.cost, j.phase1Cost, j.move)
+				}
+			}
+			worker.shared.nodes.Add(worker.nodes % cancelCheckInterval)
+		}()
+	}
+	for i := minCost; i < 20 && !s.shared.stopped.Load(); i++ {
+		for r := range roots {
+			if roots[r].cost > i {
+				continue
+			}
+			// the same roots as search would go on from, split by
+			// their first move
+			if i == 0 {
+				jobs <- job{root: &roots[r], phase1Cost: i, move: -1}
+				continue
+			}
+			if !expandsPhase1(roots[r].cost, i) {
+				continue
+			}
+			for _, m := range s.tables.phase1Moves {
+				jobs <- job{root: &roots[r], phase1Cost: i, move: m}
+			}
 		}
 	}
+	close(jobs)
+	wg.Wait()
+}
 
-	return s.solution
+// recordSolution saves a new best solution, as long as no other
+// goroutine beat it to a shorter one, and reports it to the caller if
+// they asked to hear about every solution.
+func (s *solver) recordSolution(path []int, phase1Length int) {
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
+	cost := s.tables.pathCost(path)
+	if cost >= s.minSolution() {
+		return
+	}
+	s.shared.minSolution.Store(int64(cost))
+	path, phase1Length = expandSliceTurns(path, phase1Length)
+	path, phase1Length = mergeQuarterTurns(path, phase1Length)
+	s.shared.solution = newSolution(fromOrientation(path, s.orientation), phase1Length)
+	s.shared.solution.Inverse = s.orientation%2 == 1
+	s.shared.solution.Metric = s.options.Metric
+	s.shared.solution.faceTurns = len(s.options.Moves) > 0
+	if s.options.OnSolution != nil {
+		solution := s.shared.solution
+		solution.Nodes = s.totalNodes()
+		solution.Duration = time.Since(s.shared.start)
+		s.options.OnSolution(solution)
+	}
 }
 
 func (s *solver) search(eoCoord, coCoord, ePermCoord, dist, phase1Cost int) bool {
+	s.nodes++
+	if s.cancelled() {
+		return true
+	}
 	// if the cost is zero than we have in fact found a solution to phase1 🎉
 	// begin searching for phase2 solutions
 	if phase1Cost == 0 {
 		// Don't ever end a phase 1 solution with phase 2 moves (half turns of LRFB or any UD turns)
 		// because these turns do not break phase1 (meaning we already solved it before we got here)
-		if len(s.path) > 0 {
-			m := s.path[len(s.path)-1]
-			if m < moveL || m > moveB3 || m%3 == 1 {
-				return false
-			}
+		if !s.tables.endsPhase1(s.path) {
+			return false
 		}
 		// since we haven't actually been performing moves on a real Cube, we don't know the current
 		// state. Compute it by applying all phase 1 moves to the original scramble.
 		newCube := s.scrambledCube
 		for i := 0; i < len(s.path); i++ {
-			newCube = transform(newCube, moves[s.path[i]])
+			newCube = transform(newCube, searchMoves[s.path[i]])
 		}
 		// convert to the phase 2 coordinate system
 		cpCoord := toCPCoordinate(newCube)
 		eudCoord := toUDCoordinate(newCube)
 		eePermCoord := toESliceP2Coordinate(newCube)
 
-		// limit phase 2 solutions to size 10
+		// limit phase 2 solutions to size 10 (by default)
 		// The reason to do this is that the search space for Phase 2
 		// is very large and searching beyong 10 deep into the tree becomes
 		// quite time consuming. Also, if we have a more than 10 move Phase 2
 		// there is almost certainly a less optimal phase 1 that leads to
 		// a much shorter phase 2
-		phase2Limit := int(math.Min(10, float64(s.minSolution-len(s.path))))
+		phase2Limit := int(math.Min(float64(s.options.MaxPhase2Depth), float64(s.minSolution()-s.tables.pathCost(s.path)-1)))
 
-		newCost := phase2Hueristic(cpCoord, eudCoord, eePermCoord)
+		newCost := s.tables.phase2Hueristic(cpCoord, eudCoord, eePermCoord)
 		// same as in phase 1 - our huerist might under estimate and so
 		// try again with bigger numbers if we don't find anything
-		for i := newCost; i < phase2Limit; i++ {
+		for i := newCost; i <= phase2Limit; i++ {
 			if s.searchPhase2(cpCoord, eudCoord, eePermCoord, i) {
 				// finally, if we have found a phase 2 solutions we are done
 				// and we return true back through the call stack
@@ -104,88 +558,91 @@ func (s *solver) search(eoCoord, coCoord, ePermCoord, dist, phase1Cost int) bool
 			}
 		}
 		return false
-	} else if dist != 0 || phase1Cost <= dist {
+	} else if expandsPhase1(dist, phase1Cost) {
 		// otherwise, apply every possible move and see if it is worth searching
-		for m := 0; m < moveCount; m++ {
-			// don't perform sequential moves of the same face
-			if len(s.path) > 0 {
-				lastMoveFace := s.path[len(s.path)-1] / 3
-				mFace := m / 3
-				if mFace == lastMoveFace {
-					continue
-				}
-			}
-
-			// use our lookup tables to get new coordinates and cost quickly
-			eoNew := eoLookup[eoCoord][m]
-			coNew := coLookup[coCoord][m]
-			ePermNew := eSliceP1Lookup[ePermCoord][m]
-			costNew := phase1Hueristic(eoNew, coNew, ePermNew)
-
-			// don't explore paths that are too expensive
-			if costNew >= phase1Cost {
-				continue
-			}
-
-			s.path = append(s.path, m)
-			done := s.search(eoNew, coNew, ePermNew, costNew, phase1Cost-1)
-			if done {
+		for _, m := range s.tables.phase1Moves {
+			if s.searchMove(eoCoord, coCoord, ePermCoord, dist, phase1Cost, m) {
 				return true
 			}
-			s.path = s.path[0 : len(s.path)-1]
 		}
 	}
 	return false
 }
 
+// expandsPhase1 reports whether the phase 1 search tries moves from a
+// position dist moves from the phase 2 subgroup, with phase1Cost left to
+// spend. Phase 1 ends at the first position in the subgroup, so one
+// that's already there only goes on to phase 2.
+func expandsPhase1(dist, phase1Cost int) bool {
+	return dist != 0 && phase1Cost != 0
+}
+
+// searchMove continues the phase 1 search with move m, if it's worth it.
+func (s *solver) searchMove(eoCoord, coCoord, ePermCoord, dist, phase1Cost, m int) bool {
+	// don't perform sequential moves of the same face
+	if s.tables.redundant(s.path, m) {
+		return false
+	}
+
+	// use our lookup tables to get new coordinates and cost quickly
+	eoNew := eoLookup[eoCoord][m]
+	coNew := coLookup[coCoord][m]
+	ePermNew := eSliceP1Lookup[ePermCoord][m]
+	costNew := s.tables.phase1Distance(eoNew, coNew, ePermNew, dist)
+
+	// don't explore paths that are too expensive
+	if costNew >= phase1Cost {
+		return false
+	}
+
+	s.path = append(s.path, m)
+	done := s.search(eoNew, coNew, ePermNew, costNew, phase1Cost-1)
+	s.path = s.path[0 : len(s.path)-1]
+	return done
+}
+
 func (s *solver) searchPhase2(cpCoord, eudCoord, eePermCoord, phase2Cost int) bool {
+	s.nodes++
+	if s.cancelled() {
+		return true
+	}
 	if phase2Cost == 0 {
-		solutionLength := len(s.path) + len(s.pathPhase2)
-		if solutionLength < s.minSolution {
-			s.minSolution = solutionLength
-			s.solution = toString(append(append([]int(nil), s.path...), s.pathPhase2...))
+		solutionLength := s.tables.pathCost(s.path) + s.tables.pathCost(s.pathPhase2)
+		if solutionLength < s.minSolution() {
+			s.recordSolution(append(append([]int(nil), s.path...), s.pathPhase2...), len(s.path))
 
 			// once we've found a solution sufficiently short enough bail!
-			if s.minSolution <= s.maxLength {
+			if s.minSolution() <= s.options.MaxLength {
+				s.shared.stopped.Store(true)
 				return true
 			}
 		}
 		return false
 	} else {
-		for m := 0; m < moveCount; m++ {
-			// don't do quarter turns on the side faces as these are not valid moves in phase 2
-			if m%3 != 1 && m > moveU3 && m < moveD {
-				continue
-			}
-
+		// quarter turns on the side faces aren't in phase2Moves as these are not valid moves in phase 2
+		for _, m := range s.tables.phase2Moves {
 			// don't perform sequential moves of the same face in phase 2
 			if len(s.pathPhase2) > 0 {
-				lastMoveFace := s.pathPhase2[len(s.pathPhase2)-1] / 3
-				mFace := m / 3
-				if mFace == lastMoveFace {
+				if s.tables.redundantPhase2(s.pathPhase2, m) {
 					continue
 				}
 				// also don't start a phase 2 solution with the same face as our phase 1 solution ended with
-			} else if len(s.path) > 0 {
-				lastMoveFace := s.path[len(s.path)-1] / 3
-				mFace := m / 3
-				if mFace == lastMoveFace {
-					continue
-				}
+			} else if s.tables.redundantPhase2(s.path, m) {
+				continue
 			}
 
 			// use our lookup tables to get the new coordinates and cost
 			cpNew := cpLookup[cpCoord][m]
 			eudNew := udLookup[eudCoord][m]
 			eePermNew := eSliceP2Lookup[eePermCoord][m]
-			costNew := phase2Hueristic(cpNew, eudNew, eePermNew)
+			costNew := s.tables.phase2Hueristic(cpNew, eudNew, eePermNew)
 
 			// don't explore branches that are too expensive
-			if costNew >= phase2Cost {
+			if costNew+s.tables.cost[m] > phase2Cost {
 				continue
 			}
 			s.pathPhase2 = append(s.pathPhase2, m)
-			done := s.searchPhase2(cpNew, eudNew, eePermNew, phase2Cost-1)
+			done := s.searchPhase2(cpNew, eudNew, eePermNew, phase2Cost-s.tables.cost[m])
 			if done {
 				return true
 			}
@@ -0,0 +1,46 @@
+package cube
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestSolveTo(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name     string
+		from, to Cube
+	}{
+		{"solved to pons asinorum", cubeSolved, ponsAsinorum},
+		{"pons asinorum to solved", ponsAsinorum, cubeSolved},
+		{"random to pons asinorum", RandomCube(rng), ponsAsinorum},
+		{"random to random", RandomCube(rng), RandomCube(rng)},
+		{"to itself", ponsAsinorum, ponsAsinorum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, metric := range []Metric{HTM, QTM, STM} {
+				solution, err := SolveToWithOptions(context.Background(), tt.from, tt.to, SolveOptions{Metric: metric})
+				if err != nil {
+					t.Fatalf("%v: %v", metric, err)
+				}
+				if c := tt.from.ApplySequence(solution.Moves); c != tt.to {
+					t.Errorf("%v: %v doesn't take the cube to the target", metric, solution)
+				}
+			}
+		})
+	}
+}
+
+func TestSolveToErrors(t *testing.T) {
+	twisted := cubeSolved
+	twisted.Corners[0].Orientation = 1
+	if _, err := SolveTo(twisted, cubeSolved); !errors.Is(err, ErrCornerTwist) {
+		t.Errorf("from a twisted corner: got %v, want %v", err, ErrCornerTwist)
+	}
+	if _, err := SolveTo(cubeSolved, twisted); !errors.Is(err, ErrCornerTwist) {
+		t.Errorf("to a twisted corner: got %v, want %v", err, ErrCornerTwist)
+	}
+}
@@ -0,0 +1,121 @@
+package cube
+
+// The cube has 48 symmetries, but phase 1 only cares about the UD axis
+// (it ends when every piece is oriented relative to U and D and the E
+// slice edges are in the E slice), so we use the 16 symmetries which
+// keep the UD axis in place. These are the symmetries of a square prism,
+// D4h, and each one is some combination of:
+//
+//   - a half turn of the whole cube around the FB axis (z2)
+//   - a quarter turn of the whole cube around the UD axis (y)
+//   - a reflection through the plane between L and R
+//
+// Two cubes that are symmetric to each other are the same distance from
+// being solved, and from being in the phase 2 subgroup, so pruning tables
+// only need to store one entry for every set of symmetric cubes.
+const symCount = 16
+
+// cubeZ2 is a half turn of the whole cube around the FB axis.
+var cubeZ2 = transform(transform(cubeX, cubeX), transform(cubeY, cubeY))
+
+// symRotations are the rotation part of each symmetry. Symmetry i is
+// z2^(i/8) * y^(i/2%4), reflected through the LR plane when i is odd.
+var symRotations [symCount]Cube
+
+// symInverse maps each symmetry to the one that undoes it.
+var symInverse [symCount]int
+
+// symMoves maps each move to the move it becomes under each symmetry.
+var symMoves [symCount][moveCount]int
+
+// axisRotations are cubeURF3 applied 0, 1 and 2 times, which bring the
+// RL and FB axes round to where the UD axis was.
+var axisRotations = [3]Cube{
+	cubeSolved,
+	cubeURF3,
+	transform(cubeURF3, cubeURF3),
+}
+
+// axisMoves maps each move to the same move seen after rotating the
+// cube by cubeURF3 0, 1 and 2 times.
+var axisMoves [3][moveCount]int
+
+// mirrorEdges and mirrorCorners are the position each position ends up
+// in when the cube is reflected through the plane between L and R.
+var mirrorEdges = [edgeCount]int{
+	edgeUB, edgeUL, edgeUF, edgeUR, edgeDF, edgeDL,
+	edgeDB, edgeDR, edgeFR, edgeFL, edgeBL, edgeBR,
+}
+var mirrorCorners = [cornerCount]int{
+	cornerURB, cornerUBL, cornerULF, cornerUFR,
+	cornerDRF, cornerDFL, cornerDLB, cornerDBR,
+}
+
+func initSymmetries() {
+	for i := 0; i < symCount; i++ {
+		r := cubeSolved
+		if i/8 == 1 {
+			r = transform(r, cubeZ2)
+		}
+		for j := 0; j < i/2%4; j++ {
+			r = transform(r, cubeY)
+		}
+		symRotations[i] = r
+	}
+	for i := 0; i < symCount; i++ {
+		for j := 0; j < symCount; j++ {
+			if symConjugate(symConjugate(cubeU, i), j) == cubeU &&
+				symConjugate(symConjugate(cubeF, i), j) == cubeF {
+				symInverse[i] = j
+			}
+		}
+		for m := 0; m < moveCount; m++ {
+			conjugated := symConjugate(moves[m], i)
+			for n := 0; n < moveCount; n++ {
+				if moves[n] == conjugated {
+					symMoves[i][m] = n
+				}
+			}
+		}
+	}
+	for k, r := range axisRotations {
+		for m := 0; m < moveCount; m++ {
+			conjugated := conjugate(moves[m], r)
+			for n := 0; n < moveCount; n++ {
+				if moves[n] == conjugated {
+					axisMoves[k][m] = n
+				}
+			}
+		}
+	}
+}
+
+// mirror reflects a cube through the plane between L and R. Edge
+// orientation is measured against U, D, F and B which the reflection
+// leaves alone, but the reflection reverses clockwise and counter
+// clockwise so corner twists are negated.
+func mirror(c Cube) Cube {
+	var result Cube
+	for i, e := range c.Edges {
+		result.Edges[mirrorEdges[i]] = Piece{
+			Index:       mirrorEdges[e.Index],
+			Orientation: e.Orientation,
+		}
+	}
+	for i, corner := range c.Corners {
+		result.Corners[mirrorCorners[i]] = Piece{
+			Index:       mirrorCorners[corner.Index],
+			Orientation: (3 - corner.Orientation) % 3,
+		}
+	}
+	return result
+}
+
+// symConjugate returns the cube c as seen through symmetry i.
+func symConjugate(c Cube, i int) Cube {
+	result := conjugate(c, symRotations[i])
+	if i%2 == 1 {
+		result = mirror(result)
+	}
+	return result
+}
@@ -0,0 +1,244 @@
+package cube
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+//go:generate go run ./cmd/gentables tables
+
+// The pruning tables, and the larger move tables, take a while to build,
+// the phase 1 table most of all, so once built they're saved and loaded
+// back the next time they're needed. Tables are looked for in order:
+//
+//   - embedded in the binary, when built with the embedtables tag. Run
+//     go generate first to write the tables into the tables directory.
+//   - the table directory, a cube directory in the user's cache
+//     directory by default, see SetTableDir
+//
+// and if neither has a good copy the table is built and saved to the
+// table directory for next time. Each table is in its own file:
+//
+//	magic    "CUBE"
+//	version  uint32, tableVersion
+//	name     uint8 length followed by the table's name
+//	size     uint64, length of the table in bytes
+//	checksum uint32, CRC-32 (IEEE) of the table
+//	table    size bytes
+//
+// All numbers are little endian. A file with the wrong version, name or
+// size, or a bad checksum, is ignored.
+
+const tableMagic = "CUBE"
+
+// tableVersion must be bumped whenever the contents or layout of any
+// saved table changes, so that stale files are rebuilt rather than used.
+const tableVersion = 1
+
+var tableDir = defaultTableDir()
+
+func defaultTableDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "cube")
+}
+
+// SetTableDir sets the directory tables are loaded from and saved to. An
+// empty dir turns off loading and saving, so tables are always built.
+// Tables which have already been loaded aren't affected.
+func SetTableDir(dir string) {
+	tableDir = dir
+}
+
+// loadTable fills table with the saved copy of the table called name.
+// If there isn't a good copy, build is called to fill it in and the
+// result is saved for next time.
+func loadTable(name string, table []byte, build func()) {
+	if readTable(name, table) {
+		return
+	}
+	build()
+	saveTable(name, table)
+}
+
+// loadLookup is loadTable for move tables. Every coordinate fits in 16
+// bits, so they're saved as uint16s.
+func loadLookup(name string, lookup [][searchMoveCount]int, build func()) {
+	data := make([]byte, len(lookup)*searchMoveCount*2)
+	if readTable(name, data) {
+		for i := range lookup {
+			for m := range lookup[i] {
+				lookup[i][m] = int(binary.LittleEndian.Uint16(data[(i*searchMoveCount+m)*2:]))
+			}
+		}
+		return
+	}
+	build()
+	saveTable(name, encodeLookup(lookup))
+}
+
+func encodeLookup(lookup [][searchMoveCount]int) []byte {
+	data := make([]byte, 0, len(lookup)*searchMoveCount*2)
+	for i := range lookup {
+		for _, next := range lookup[i] {
+			data = binary.LittleEndian.AppendUint16(data, uint16(next))
+		}
+	}
+	return data
+}
+
+// readTable reads the table called name into table from wherever it
+// can first find a good copy. On failure table is left zeroed, ready to
+// be built.
+func readTable(name string, table []byte) bool {
+	file := name + ".tbl"
+	if embeddedTables != nil {
+		if f, err := embeddedTables.Open("tables/" + file); err == nil {
+			err = decodeTable(f, name, table)
+			f.Close()
+			if err == nil {
+				return true
+			}
+		}
+	}
+	if tableDir != "" {
+		if f, err := os.Open(filepath.Join(tableDir, file)); err == nil {
+			err = decodeTable(f, name, table)
+			f.Close()
+			if err == nil {
+				return true
+			}
+		}
+	}
+	clear(table)
+	return false
+}
+
+var errBadTable = errors.New("bad table file")
+
+func decodeTable(r io.Reader, name string, table []byte) error {
+	br := bufio.NewReader(r)
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+		NameLen uint8
+	}
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if string(header.Magic[:]) != tableMagic || header.Version != tableVersion {
+		return errBadTable
+	}
+	fileName := make([]byte, header.NameLen)
+	if _, err := io.ReadFull(br, fileName); err != nil {
+		return err
+	}
+	var sizes struct {
+		Size     uint64
+		Checksum uint32
+	}
+	if err := binary.Read(br, binary.LittleEndian, &sizes); err != nil {
+		return err
+	}
+	if string(fileName) != name || sizes.Size != uint64(len(table)) {
+		return errBadTable
+	}
+	if _, err := io.ReadFull(br, table); err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(table) != sizes.Checksum {
+		return errBadTable
+	}
+	return nil
+}
+
+func encodeTable(w io.Writer, name string, table []byte) error {
+	var header bytes.Buffer
+	header.WriteString(tableMagic)
+	header.Write(binary.LittleEndian.AppendUint32(nil, tableVersion))
+	header.WriteByte(byte(len(name)))
+	header.WriteString(name)
+	header.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(table))))
+	header.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(table)))
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(table)
+	return err
+}
+
+// saveTable saves a table to the table directory, if there is one. This
+// is only a cache, so failures are ignored and the table is just built
+// again next time.
+func saveTable(name string, table []byte) {
+	if tableDir == "" {
+		return
+	}
+	writeTable(tableDir, name, table)
+}
+
+// writeTable writes a table file into dir. The file is written under a
+// temporary name and renamed into place, so that other processes never
+// see half a table.
+func writeTable(dir, name string, table []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, name+".tbl.*")
+	if err != nil {
+		return err
+	}
+	err = f.Chmod(0o644)
+	if err == nil {
+		err = encodeTable(f, name, table)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, name+".tbl"))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// WriteTables builds every table the two phase search uses, in every
+// metric, and writes them into dir, for example to embed them in a binary
+// built with the embedtables tag. The optimal search's tables are much
+// larger and are only ever saved to the table directory.
+func WriteTables(dir string) error {
+	type namedTable struct {
+		name  string
+		table []byte
+	}
+	Prepare()
+	tables := []namedTable{
+		{"cp", encodeLookup(cpLookup[:])},
+		{"ud", encodeLookup(udLookup[:])},
+	}
+	for metric, m := range metrics {
+		PrepareMetric(Metric(metric))
+		tables = append(tables,
+			namedTable{"phase2-corners" + m.suffix, m.phase2CornerESliceMinMoves},
+			namedTable{"phase2-edges" + m.suffix, m.phase2AllEdgesMinMoves},
+			namedTable{"phase1" + m.suffix, m.phase1MinMoves},
+		)
+	}
+	for _, t := range tables {
+		if err := writeTable(dir, t.name, t.table); err != nil {
+			return fmt.Errorf("writing %s table: %w", t.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build embedtables
+
+package cube
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed tables/*.tbl
+var tableFiles embed.FS
+
+// embeddedTables holds the tables compiled into the binary
+var embeddedTables fs.FS = tableFiles
@@ -0,0 +1,9 @@
+//go:build !embedtables
+
+package cube
+
+import "io/fs"
+
+// embeddedTables is nil without the embedtables tag, so tables are only
+// loaded from the table directory
+var embeddedTables fs.FS
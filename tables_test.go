@@ -0,0 +1,78 @@
+package cube
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTable(t *testing.T) {
+	want := []byte("a table which is long enough to be worth a checksum")
+	encode := func(name string, table []byte) []byte {
+		var b bytes.Buffer
+		if err := encodeTable(&b, name, table); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	good := encode("test", want)
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"missing", nil},
+		{"empty", []byte{}},
+		{"truncated header", good[:10]},
+		{"truncated table", good[:len(good)-1]},
+		{"bad checksum", append(good[:len(good)-1:len(good)-1], good[len(good)-1]^1)},
+		{"wrong magic", append([]byte("EBUC"), good[4:]...)},
+		{"wrong version", append(append(good[:4:4], binary.LittleEndian.AppendUint32(nil, tableVersion+1)...), good[8:]...)},
+		{"wrong name", encode("tset", want)},
+		{"wrong size", encode("test", want[1:])},
+	}
+	old := tableDir
+	t.Cleanup(func() { SetTableDir(old) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			SetTableDir(dir)
+			path := filepath.Join(dir, "test.tbl")
+			if tt.file != nil {
+				if err := os.WriteFile(path, tt.file, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			table := make([]byte, len(want))
+			built := false
+			loadTable("test", table, func() {
+				built = true
+				copy(table, want)
+			})
+			if !built {
+				t.Fatal("the table was read instead of built")
+			}
+			if !bytes.Equal(table, want) {
+				t.Errorf("got %q, want %q", table, want)
+			}
+
+			// the table built should have replaced the bad file
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, good) {
+				t.Errorf("the file wasn't saved again")
+			}
+			table = make([]byte, len(want))
+			loadTable("test", table, func() {
+				t.Error("the saved table was built again")
+			})
+			if !bytes.Equal(table, want) {
+				t.Errorf("read %q, want %q", table, want)
+			}
+		})
+	}
+}
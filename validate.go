@@ -0,0 +1,110 @@
+package cube
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The reasons a cube can fail validation. These are wrapped in an
+// *InvalidCubeError, so check for them with errors.Is.
+var (
+	ErrInvalidPiece      = errors.New("piece index or orientation out of range")
+	ErrDuplicateEdge     = errors.New("edge appears more than once, so another edge is missing")
+	ErrDuplicateCorner   = errors.New("corner appears more than once, so another corner is missing")
+	ErrEdgeFlip          = errors.New("edge orientations do not sum to a multiple of 2, an edge is flipped")
+	ErrCornerTwist       = errors.New("corner orientations do not sum to a multiple of 3, a corner is twisted")
+	ErrPermutationParity = errors.New("edge and corner permutation parity differ, two pieces are swapped")
+)
+
+// InvalidCubeError describes why a cube is impossible to reach from
+// the solved state by turning faces.
+type InvalidCubeError struct {
+	// Err is one of the Err* reasons above
+	Err error
+	// Piece names the offending piece, e.g. "UF" or "DRF", when the
+	// problem is with a single piece
+	Piece string
+}
+
+func (e *InvalidCubeError) Error() string {
+	if e.Piece != "" {
+		return fmt.Sprintf("invalid cube: %s: %v", e.Piece, e.Err)
+	}
+	return fmt.Sprintf("invalid cube: %v", e.Err)
+}
+
+func (e *InvalidCubeError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the cube is solvable. A cube is solvable when
+// every piece appears exactly once, the edge orientations sum to a
+// multiple of 2, the corner orientations sum to a multiple of 3, and
+// the edge and corner permutations have the same parity. The first
+// problem found is returned as an *InvalidCubeError.
+func (c Cube) Validate() error {
+	var edgeSeen [edgeCount]bool
+	for i, e := range c.Edges {
+		if e.Index < 0 || e.Index >= edgeCount || e.Orientation < 0 || e.Orientation > 1 {
+			return &InvalidCubeError{Err: ErrInvalidPiece, Piece: edgeName(i)}
+		}
+		if edgeSeen[e.Index] {
+			return &InvalidCubeError{Err: ErrDuplicateEdge, Piece: edgeName(e.Index)}
+		}
+		edgeSeen[e.Index] = true
+	}
+	var cornerSeen [cornerCount]bool
+	for i, corner := range c.Corners {
+		if corner.Index < 0 || corner.Index >= cornerCount || corner.Orientation < 0 || corner.Orientation > 2 {
+			return &InvalidCubeError{Err: ErrInvalidPiece, Piece: cornerName(i)}
+		}
+		if cornerSeen[corner.Index] {
+			return &InvalidCubeError{Err: ErrDuplicateCorner, Piece: cornerName(corner.Index)}
+		}
+		cornerSeen[corner.Index] = true
+	}
+
+	edgeOrientation := 0
+	for _, e := range c.Edges {
+		edgeOrientation += e.Orientation
+	}
+	if edgeOrientation%2 != 0 {
+		return &InvalidCubeError{Err: ErrEdgeFlip}
+	}
+
+	cornerOrientation := 0
+	for _, corner := range c.Corners {
+		cornerOrientation += corner.Orientation
+	}
+	if cornerOrientation%3 != 0 {
+		return &InvalidCubeError{Err: ErrCornerTwist}
+	}
+
+	var edgePerm [edgeCount]int
+	for i, e := range c.Edges {
+		edgePerm[i] = e.Index
+	}
+	var cornerPerm [cornerCount]int
+	for i, corner := range c.Corners {
+		cornerPerm[i] = corner.Index
+	}
+	if permutationParity(edgePerm[:]) != permutationParity(cornerPerm[:]) {
+		return &InvalidCubeError{Err: ErrPermutationParity}
+	}
+
+	return nil
+}
+
+// permutationParity returns 0 for an even permutation and 1 for an odd
+// one by counting inversions.
+func permutationParity(perm []int) int {
+	inversions := 0
+	for i := 0; i < len(perm); i++ {
+		for j := i + 1; j < len(perm); j++ {
+			if perm[j] < perm[i] {
+				inversions++
+			}
+		}
+	}
+	return inversions % 2
+}
@@ -0,0 +1,56 @@
+package cube
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if c := RandomCube(rng); c.Validate() != nil {
+			t.Fatalf("%v: %v", c, c.Validate())
+		}
+	}
+	if err := cubeSolved.Validate(); err != nil {
+		t.Errorf("solved: %v", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Cube)
+		err    error
+		piece  string
+	}{
+		{"twisted corner", func(c *Cube) { c.Corners[2].Orientation = 1 }, ErrCornerTwist, ""},
+		{"flipped edge", func(c *Cube) { c.Edges[2].Orientation = 1 }, ErrEdgeFlip, ""},
+		{"two edges swapped", func(c *Cube) { c.Edges[0], c.Edges[1] = c.Edges[1], c.Edges[0] }, ErrPermutationParity, ""},
+		{"two corners swapped", func(c *Cube) { c.Corners[4], c.Corners[6] = c.Corners[6], c.Corners[4] }, ErrPermutationParity, ""},
+		{"duplicate edge", func(c *Cube) { c.Edges[5] = c.Edges[0] }, ErrDuplicateEdge, "UB"},
+		{"duplicate corner", func(c *Cube) { c.Corners[5] = c.Corners[4] }, ErrDuplicateCorner, "DFL"},
+		{"edge index", func(c *Cube) { c.Edges[3].Index = edgeCount }, ErrInvalidPiece, "UL"},
+		{"edge orientation", func(c *Cube) { c.Edges[8].Orientation = 2 }, ErrInvalidPiece, "FL"},
+		{"corner index", func(c *Cube) { c.Corners[1].Index = -1 }, ErrInvalidPiece, "URB"},
+		{"corner orientation", func(c *Cube) { c.Corners[7].Orientation = 3 }, ErrInvalidPiece, "DLB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cubeSolved
+			tt.change(&c)
+			err := c.Validate()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			var invalidErr *InvalidCubeError
+			if !errors.As(err, &invalidErr) {
+				t.Fatalf("%v is not an *InvalidCubeError", err)
+			}
+			if invalidErr.Piece != tt.piece {
+				t.Errorf("piece is %q, want %q", invalidErr.Piece, tt.piece)
+			}
+		})
+	}
+}
@@ -27,7 +27,9 @@ onmessage = async (event) => {
     }
 
     try {
-      const result = globalThis.solve(message);
+      const result = globalThis.solve(message, 30000, (partial) =>
+        postMessage(partial)
+      );
       postMessage(result);
     } catch (err) {
       console.error("Error calling Go function:", err);